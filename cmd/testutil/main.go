@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Educentr/goat"
 	"github.com/Educentr/goat/testutil"
 )
 
@@ -16,6 +17,10 @@ func main() {
 	switch os.Args[1] {
 	case "generate-env":
 		generateEnv()
+	case "update-log-fields":
+		updateLogFields()
+	case "validate-log-fields":
+		validateLogFields()
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -31,6 +36,11 @@ func printUsage() {
 	fmt.Println("Commands:")
 	fmt.Println("  generate-env [input] [output]  Convert TREE.conf to .env format")
 	fmt.Println("                                 Default: tests/etc/onlineconf/TREE.conf -> onlineconf.env")
+	fmt.Println("  update-log-fields <log> [file] Rewrite the log fields CSV with fields of a captured JSON log")
+	fmt.Println("                                 Default file: $GOAT_LOG_FIELDS_FILE")
+	fmt.Println("  validate-log-fields <dir> [file]")
+	fmt.Println("                                 Validate log fields of all packages written to $GOAT_LOG_FIELDS_DIR")
+	fmt.Println("                                 GOAT_UPDATE_LOG_FIELDS=true rewrites the CSV file instead")
 	fmt.Println("  help                           Show this help")
 }
 
@@ -58,3 +68,63 @@ func generateEnv() {
 
 	fmt.Printf("Generated %s with %d variables\n", outputPath, len(envVars))
 }
+
+func updateLogFields() {
+	if len(os.Args) < 3 {
+		printUsage()
+		os.Exit(1)
+	}
+	logPath := os.Args[2]
+	fieldsPath := os.Getenv("GOAT_LOG_FIELDS_FILE")
+	if len(os.Args) > 3 {
+		fieldsPath = os.Args[3]
+	}
+	if fieldsPath == "" {
+		fmt.Println("Error: log fields file is not set, pass it or set GOAT_LOG_FIELDS_FILE")
+		os.Exit(1)
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		fmt.Printf("Error opening %s: %v\n", logPath, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	fields, unmarshalErrors, err := goat.CollectLogFields(f)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", logPath, err)
+		os.Exit(1)
+	}
+
+	update, err := goat.UpdateLogFieldsFile(fieldsPath, fields)
+	if err != nil {
+		fmt.Printf("Error updating %s: %v\n", fieldsPath, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Updated %s: %d added, %d removed, %d changed, %d lines are not JSON\n",
+		fieldsPath, len(update.Added), len(update.Removed), len(update.Changed), unmarshalErrors)
+}
+
+func validateLogFields() {
+	if len(os.Args) < 3 {
+		printUsage()
+		os.Exit(1)
+	}
+	dir := os.Args[2]
+	fieldsPath := os.Getenv("GOAT_LOG_FIELDS_FILE")
+	if len(os.Args) > 3 {
+		fieldsPath = os.Args[3]
+	}
+	if fieldsPath == "" {
+		fmt.Println("Error: log fields file is not set, pass it or set GOAT_LOG_FIELDS_FILE")
+		os.Exit(1)
+	}
+
+	if err := goat.ValidateLogFieldsDir(dir, fieldsPath); err != nil {
+		fmt.Printf("Error validating log fields of %s: %v\n", dir, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Log fields of %s match %s\n", dir, fieldsPath)
+}
@@ -4,23 +4,52 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/Educentr/goat/services"
 )
 
+const (
+	DefaultStartTimeout = 10 * time.Minute
+	DefaultStopTimeout  = time.Minute
+)
+
 // EnvConfig holds configuration for the testing environment.
-// This is kept for future extensibility but currently empty.
 type EnvConfig struct {
-	// Reserved for future configuration options
+	// StartTimeout limits the start of services in CallMain, DefaultStartTimeout if zero.
+	// Pulling images counts, so it should be generous.
+	StartTimeout time.Duration
+	// StopTimeout limits the stop of services in CallMain, DefaultStopTimeout if zero
+	StopTimeout time.Duration
 }
 
 type Env struct {
-	manager         *services.Manager
-	Executor        BaseExecutor
-	Conf            EnvConfig
-	logFields       map[string]string
-	unmarshalErrors int
+	manager     *services.Manager
+	Executor    BaseExecutor
+	executors   map[BaseExecutor]struct{}
+	Conf        EnvConfig
+	logStats    LogFieldStats
+	logStatsMu  sync.Mutex
+	executorsMu sync.Mutex
+}
+
+func (c EnvConfig) startTimeout() time.Duration {
+	if c.StartTimeout > 0 {
+		return c.StartTimeout
+	}
+	return DefaultStartTimeout
+}
+
+func (c EnvConfig) stopTimeout() time.Duration {
+	if c.StopTimeout > 0 {
+		return c.StopTimeout
+	}
+	return DefaultStopTimeout
 }
 
 func getFieldsCollectorFilePath() string {
@@ -28,6 +57,11 @@ func getFieldsCollectorFilePath() string {
 	return os.Getenv("GOAT_LOG_FIELDS_FILE")
 }
 
+// getFieldsCollectorDir returns the directory for log field stats of test packages, they are validated together
+func getFieldsCollectorDir() string {
+	return os.Getenv("GOAT_LOG_FIELDS_DIR")
+}
+
 func SafeCallError(fn func() error) (err error) {
 	defer func() {
 		if errRecover := recover(); errRecover != nil {
@@ -38,14 +72,10 @@ func SafeCallError(fn func() error) (err error) {
 	return
 }
 
-func (e *Env) mergeLogFieldStats(logFields map[string]string, unmarshalErrors int) {
-	if e.logFields == nil {
-		e.logFields = make(map[string]string)
-	}
-	for k, v := range logFields {
-		e.logFields[k] = v
-	}
-	e.unmarshalErrors += unmarshalErrors
+func (e *Env) mergeLogFieldStats(stats LogFieldStats) {
+	e.logStatsMu.Lock()
+	defer e.logStatsMu.Unlock()
+	e.logStats.Merge(stats)
 }
 
 // NewEnv creates a new environment with an existing services.Manager.
@@ -101,20 +131,101 @@ func (e *Env) Stop(ctx context.Context) error {
 	return e.manager.Stop(ctx)
 }
 
+// trackExecutor remembers a started executor to stop it if the tests are interrupted
+func (e *Env) trackExecutor(exe BaseExecutor) {
+	if e == nil {
+		return
+	}
+	e.executorsMu.Lock()
+	defer e.executorsMu.Unlock()
+	if e.executors == nil {
+		e.executors = make(map[BaseExecutor]struct{})
+	}
+	e.executors[exe] = struct{}{}
+}
+
+func (e *Env) untrackExecutor(exe BaseExecutor) {
+	if e == nil {
+		return
+	}
+	e.executorsMu.Lock()
+	defer e.executorsMu.Unlock()
+	delete(e.executors, exe)
+}
+
+// stopExecutors stops executors of flows that were not stopped
+func (e *Env) stopExecutors() {
+	e.executorsMu.Lock()
+	executors := e.executors
+	e.executors = nil
+	e.executorsMu.Unlock()
+
+	for exe := range executors {
+		if err := SafeCallError(exe.Stop); err != nil {
+			println("failed to stop executor ", err.Error())
+		}
+	}
+}
+
 // CallMain is a helper function to be called from TestMain.
 // It starts the environment, runs tests, stops the environment, and validates log fields.
-// Uses background context with no timeout - if you need timeouts, manage them yourself.
+// Start and stop are limited by EnvConfig timeouts. On SIGINT or SIGTERM the start is cancelled,
+// executors of running flows and services are stopped and the process exits with 128+signal;
+// a second signal exits at once.
 func CallMain(env *Env, m *testing.M) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	os.Exit(runMain(env, m.Run, signals, os.Exit))
+}
+
+func runMain(env *Env, run func() int, signals <-chan os.Signal, exit func(code int)) int {
 	var exitCode = 0
+	mainEnv.Store(env)
+	var interruptCode atomic.Int32
+
+	ctx, cancelStart := context.WithTimeout(context.Background(), env.Conf.startTimeout())
+	defer cancelStart()
+
+	var teardownOnce sync.Once
+	teardown := func() {
+		teardownOnce.Do(func() {
+			env.stopExecutors()
+			stopCtx, cancel := context.WithTimeout(context.Background(), env.Conf.stopTimeout())
+			defer cancel()
+			if err := env.Stop(stopCtx); err != nil {
+				println("failed to stop environment ", err.Error())
+			}
+		})
+	}
 
-	ctx := context.Background()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-signals:
+		case <-done:
+			return
+		}
+		code := signalExitCode(sig)
+		interruptCode.Store(int32(code)) //nolint:gosec // exit codes are small
+		println("interrupted by", sig.String(), "stopping environment, repeat to exit at once")
+		cancelStart()
+		go func() {
+			if _, ok := <-signals; ok {
+				exit(code)
+			}
+		}()
+		teardown()
+		exit(code)
+	}()
 
 	if err := env.Start(ctx); err != nil {
 		println("can't start environment ", err.Error())
 		exitCode = 1
 	} else {
 		if safeErr := SafeCallError(func() error {
-			exitCode = m.Run()
+			exitCode = run()
 			return nil
 		}); safeErr != nil {
 			println("panic detected ", safeErr.Error())
@@ -122,15 +233,22 @@ func CallMain(env *Env, m *testing.M) {
 		}
 	}
 
-	_ = env.Stop(ctx) //nolint:errcheck // best effort cleanup, exit code already set
+	teardown()
+	if code := interruptCode.Load(); code != 0 {
+		return int(code)
+	}
 
-	logFieldsPath := getFieldsCollectorFilePath()
-	if logFieldsPath != "" {
-		if err := validateLogFields(logFieldsPath, env.logFields, env.unmarshalErrors); err != nil {
-			println("failed validate log fields ", err.Error())
-			exitCode = 1
-		}
+	if err := env.checkLogFields(); err != nil {
+		println("failed validate log fields ", err.Error())
+		exitCode = 1
 	}
 
-	os.Exit(exitCode)
+	return exitCode
+}
+
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
 }
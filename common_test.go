@@ -0,0 +1,137 @@
+package goat
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Educentr/goat/services"
+	"github.com/stretchr/testify/require"
+	testcontainers "github.com/testcontainers/testcontainers-go"
+)
+
+type (
+	// fakeRunner starts fakeContainer or blocks until the start is cancelled
+	fakeRunner struct {
+		container *fakeContainer
+		name      string
+		block     bool
+	}
+
+	fakeContainer struct {
+		testcontainers.Container
+		terminated atomic.Bool
+	}
+
+	fakeExecutor struct {
+		stopped atomic.Bool
+	}
+)
+
+func (r *fakeRunner) Run(ctx context.Context, _ ...testcontainers.ContainerCustomizer) (testcontainers.Container, error) {
+	if r.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return r.container, nil
+}
+
+func (r *fakeRunner) Name() string { return r.name }
+
+func (c *fakeContainer) Terminate(context.Context, ...testcontainers.TerminateOption) error {
+	c.terminated.Store(true)
+	return nil
+}
+
+func (e *fakeExecutor) Start() error  { return nil }
+func (e *fakeExecutor) Run() error    { return nil }
+func (e *fakeExecutor) IsDebug() bool { return false }
+func (e *fakeExecutor) Stop() error {
+	e.stopped.Store(true)
+	return nil
+}
+
+func newTestEnv(t *testing.T, conf EnvConfig, lazy bool, runners ...*fakeRunner) *Env {
+	t.Helper()
+	registry := services.NewRegistry()
+	servicesMap := make(services.ServicesMap)
+	for _, r := range runners {
+		registry.MustRegister(r.name, r)
+		servicesMap[r.name] = services.Config{}
+	}
+	cfg := services.DefaultManagerConfig()
+	cfg.Logger = services.NewNoopLogger()
+	cfg.Lazy = lazy
+	return NewEnv(conf, services.NewManagerWithRegistry(servicesMap, cfg, registry))
+}
+
+func TestRunMain(t *testing.T) {
+	container := &fakeContainer{}
+	env := newTestEnv(t, EnvConfig{}, false, &fakeRunner{name: "db", container: container})
+
+	code := runMain(env, func() int { return 3 }, make(chan os.Signal), func(int) { t.Fatal("unexpected exit") })
+	require.Equal(t, 3, code)
+	require.True(t, container.terminated.Load())
+}
+
+func TestRunMainStartTimeout(t *testing.T) {
+	env := newTestEnv(t, EnvConfig{StartTimeout: 50 * time.Millisecond}, false, &fakeRunner{name: "db", block: true})
+
+	code := runMain(env, func() int {
+		t.Fatal("tests must not run")
+		return 0
+	}, make(chan os.Signal), func(int) { t.Fatal("unexpected exit") })
+	require.Equal(t, 1, code)
+}
+
+func TestRunMainInterrupted(t *testing.T) {
+	container := &fakeContainer{}
+	env := newTestEnv(t, EnvConfig{}, false, &fakeRunner{name: "db", container: container})
+	app := &fakeExecutor{}
+
+	signals := make(chan os.Signal, 1)
+	exited := make(chan int, 1)
+	code := runMain(env, func() int {
+		env.trackExecutor(app)
+		signals <- syscall.SIGINT
+		// the process exits while tests are running
+		return <-exited
+	}, signals, func(code int) { exited <- code })
+
+	require.Equal(t, 130, code)
+	require.True(t, app.stopped.Load())
+	require.True(t, container.terminated.Load())
+}
+
+func TestRunMainInterruptedStart(t *testing.T) {
+	env := newTestEnv(t, EnvConfig{}, false, &fakeRunner{name: "db", block: true})
+
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+	exited := make(chan int, 1)
+	code := runMain(env, func() int {
+		t.Fatal("tests must not run")
+		return 0
+	}, signals, func(code int) { exited <- code })
+
+	require.Equal(t, 143, code)
+	require.Equal(t, 143, <-exited)
+}
+
+func TestRequire(t *testing.T) {
+	db := &fakeContainer{}
+	env := newTestEnv(t, EnvConfig{}, true, &fakeRunner{name: "db", container: db}, &fakeRunner{name: "cache", container: &fakeContainer{}})
+
+	code := runMain(env, func() int {
+		require.Empty(t, env.Manager().ListRunning())
+		Require(t, "db")
+		require.Equal(t, []string{"db"}, env.Manager().ListRunning())
+		return 0
+	}, make(chan os.Signal), func(int) { t.Fatal("unexpected exit") })
+
+	require.Zero(t, code)
+	require.True(t, db.terminated.Load())
+}
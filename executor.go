@@ -7,7 +7,9 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 	"syscall"
@@ -19,9 +21,15 @@ import (
 type (
 	fieldsCollector struct {
 		fields          map[string]string
+		parser          LogLineParser
+		schema          *LogSchema
+		violations      []LogViolation
+		ignorePrefixes  []string
+		ignorePatterns  []*regexp.Regexp
 		buf             bytes.Buffer
 		m               sync.Mutex
 		unmarshalErrors int
+		lines           int
 	}
 
 	// PatternDetector special structure what can handle stdout/err and detect pattern in log
@@ -45,9 +53,14 @@ type (
 		stdoutDetector *PatternDetector
 		stderrDetector *PatternDetector
 		fieldsParser   *fieldsCollector
+		fieldsFile     string
 		cmd            *exec.Cmd
 		outputFile     *os.File
 		errorsFile     *os.File
+		outputPath     string
+		errorsPath     string
+		stdout         []io.Writer
+		stderr         []io.Writer
 		debug          bool
 	}
 )
@@ -83,10 +96,63 @@ func diffMaps(map1, map2 map[string]string) (onlyInMap1, onlyInMap2, differentVa
 	return onlyInMap1, onlyInMap2, differentValues
 }
 
-func newFieldsCollector() *fieldsCollector {
-	return &fieldsCollector{
+func newFieldsCollector(opts ...LogFieldsOption) *fieldsCollector {
+	fc := &fieldsCollector{
 		fields: make(map[string]string),
+		parser: envLogParser(),
 	}
+	for _, opt := range opts {
+		opt(fc)
+	}
+	return fc
+}
+
+// newFileFieldsCollector creates a collector validating lines with the schema if the fields file is one
+func newFileFieldsCollector(fieldsPath string, opts ...LogFieldsOption) *fieldsCollector {
+	fc := newFieldsCollector(opts...)
+	if isLogSchemaFile(fieldsPath) {
+		// a broken schema is reported by validateLogSchema after the tests
+		if schema, err := LoadLogSchema(fieldsPath); err != nil {
+			fmt.Printf("failed to load log schema %s: %v\n", fieldsPath, err)
+		} else {
+			fc.schema = schema
+		}
+	}
+	return fc
+}
+
+// ignored reports whether the line is blank or matches ignore options
+func (fc *fieldsCollector) ignored(line []byte) bool {
+	text := strings.TrimRight(string(line), "\r\n")
+	if strings.TrimSpace(text) == "" {
+		return true
+	}
+	for _, prefix := range fc.ignorePrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	for _, re := range fc.ignorePatterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogFieldStats implements LogFieldsReporter
+func (fc *fieldsCollector) LogFieldStats() LogFieldStats {
+	fc.m.Lock()
+	defer fc.m.Unlock()
+	stats := LogFieldStats{
+		Fields:          make(map[string]string, len(fc.fields)),
+		Violations:      append([]LogViolation(nil), fc.violations...),
+		UnmarshalErrors: fc.unmarshalErrors,
+	}
+	for k, v := range fc.fields {
+		stats.Fields[k] = v
+	}
+	return stats
 }
 
 func processHeaders(headers []string) (fieldNameIndex, typeIndex int, err error) {
@@ -185,6 +251,7 @@ func validateLogFields(fieldsCollectorFilePath string, fields map[string]string,
 	m1, m2, dm := diffMaps(fields, fieldsInFile)
 	if len(m1) != 0 || len(m2) != 0 || len(dm) != 0 {
 		printInformation(m1, m2, dm)
+		fmt.Println("run with GOAT_UPDATE_LOG_FIELDS=true or -goat.update-log-fields to update the file")
 		failed = true
 	}
 	if unmarshalErrors != 0 {
@@ -206,23 +273,38 @@ func (fc *fieldsCollector) Write(p []byte) (n int, err error) {
 	for {
 		l, err2 := fc.buf.ReadBytes('\n')
 		if err2 == nil {
-			var m map[string]interface{}
-			err = json.Unmarshal(l, &m)
-			if err != nil {
+			fc.lines++
+			if fc.ignored(l) {
+				continue
+			}
+			// the error is counted, returning it would break the other stdout writers
+			m, parseErr := fc.parser.Parse(l)
+			if parseErr != nil {
 				fc.unmarshalErrors++
-				fmt.Println("ERROR DURING UNMARSHALING OF LOG LINE: ", string(l), "ERROR: ", err)
+				fmt.Println("ERROR DURING PARSING OF LOG LINE: ", string(l), "ERROR: ", parseErr)
 				continue
 			}
 
+			if fc.schema != nil {
+				for _, v := range fc.schema.Validate(m) {
+					v.Line = fc.lines
+					v.Text = strings.TrimSpace(string(l))
+					fc.violations = append(fc.violations, v)
+				}
+			}
+
 			for k, v := range m {
 				_, ok := fc.fields[k]
-				if ok {
+				// null has no type, the field is recorded by a line with a value
+				if ok || v == nil {
 					continue
 				}
 				fc.fields[k] = reflect.TypeOf(v).String()
 			}
 			continue
 		}
+		// keep the incomplete line until the next write
+		fc.buf.Write(l)
 		break
 	}
 	return n, err
@@ -248,13 +330,25 @@ func (pt *PatternDetector) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
+// LogFieldStats returns fields seen in stdout, it is empty when the fields file is not set
+func (b *Executor) LogFieldStats() LogFieldStats {
+	if b.fieldsParser == nil {
+		return LogFieldStats{}
+	}
+	stats := b.fieldsParser.LogFieldStats()
+	stats.File = b.fieldsFile
+	return stats
+}
+
 // Start starts the binary but does not wait for it to complete.
 func (b *Executor) Start() error {
+	b.openOutputs()
 	return b.cmd.Start()
 }
 
 // Run executes the binary and waits for it to complete.
 func (b *Executor) Run() error {
+	b.openOutputs()
 	if err := b.cmd.Run(); err != nil {
 		return err
 	}
@@ -314,23 +408,45 @@ func (b *Executor) checkOutput() error {
 	return nil
 }
 
-func debugExecutor(b string, m map[string]string, args ...string) *Executor {
+// executorConfig holds executor options, NewExecutor reads them from GOAT_* variables
+// and ExecutorBuilder overrides them without touching the process env
+type executorConfig struct {
+	debugPort     string
+	outputFile    string
+	errorsFile    string
+	fieldsFile    string
+	debug         bool
+	disableStdout bool
+}
+
+func executorConfigFromEnv() executorConfig {
+	return executorConfig{
+		debug:         strings.ToLower(os.Getenv("GOAT_REMOTE_DEBUG")) == TrueValue,
+		debugPort:     os.Getenv("GOAT_REMOTE_DEBUG_PORT"),
+		disableStdout: os.Getenv("GOAT_DISABLE_STDOUT") == TrueValue,
+		outputFile:    os.Getenv("GOAT_OUTPUT_FILE"),
+		errorsFile:    os.Getenv("GOAT_OUTPUT_ERRORS_FILE"),
+		fieldsFile:    getFieldsCollectorFilePath(),
+	}
+}
+
+func debugExecutor(b string, m map[string]string, cfg executorConfig, args ...string) *Executor {
 	port := "2345"
-	if p := os.Getenv("GOAT_REMOTE_DEBUG_PORT"); p != "" {
-		port = p
+	if cfg.debugPort != "" {
+		port = cfg.debugPort
 	}
 
 	args = append([]string{
 		"--listen=:" + port, "--headless=true", "--api-version=2",
 		"--accept-multiclient", "exec", b, "--",
 	}, args...)
-	e := directExecutor("dlv", m, args...)
+	e := directExecutor("dlv", m, cfg, args...)
 	e.debug = true
 
 	return e
 }
 
-func directExecutor(binary string, envs map[string]string, args ...string) *Executor {
+func directExecutor(binary string, envs map[string]string, cfg executorConfig, args ...string) *Executor {
 	// fmt.Println("create binary executor", binary, envs, args)
 	fmt.Println("create binary executor", binary, args)
 
@@ -350,51 +466,79 @@ func directExecutor(binary string, envs map[string]string, args ...string) *Exec
 		pattern: "WARNING: DATA RACE",
 	}
 
-	stdOutWriters := []io.Writer{b.stdoutDetector}
-	stdErrWriters := []io.Writer{b.stderrDetector, os.Stderr}
+	b.stdout = []io.Writer{b.stdoutDetector}
+	b.stderr = []io.Writer{b.stderrDetector, os.Stderr}
+
+	if !cfg.disableStdout {
+		b.stdout = append(b.stdout, os.Stdout)
+	}
 
-	disableStdout := os.Getenv("GOAT_DISABLE_STDOUT") == TrueValue
+	// output files are created by Start, so parallel flows can isolate them before
+	b.outputPath = cfg.outputFile
+	b.errorsPath = cfg.errorsFile
 
-	if !disableStdout {
-		stdOutWriters = append(stdOutWriters, os.Stdout)
+	if cfg.fieldsFile != "" {
+		b.fieldsFile = cfg.fieldsFile
+		b.fieldsParser = newFileFieldsCollector(cfg.fieldsFile)
+		b.stdout = append(b.stdout, b.fieldsParser)
 	}
 
-	outputFilePath := os.Getenv("GOAT_OUTPUT_FILE")
+	return b
+}
+
+// openOutputs creates the output files and sets writers of the command
+func (b *Executor) openOutputs() {
+	stdOutWriters := b.stdout
+	stdErrWriters := b.stderr
 
-	if outputFilePath != "" {
-		if outputFile, err := os.Create(outputFilePath); err != nil {
-			fmt.Printf("failed to create output file %s: %v, using stdout\n", outputFilePath, err)
+	if b.outputPath != "" && b.outputFile == nil {
+		if outputFile, err := os.Create(b.outputPath); err != nil {
+			fmt.Printf("failed to create output file %s: %v, using stdout\n", b.outputPath, err)
 		} else {
 			b.outputFile = outputFile
-			stdOutWriters = append(stdOutWriters, outputFile)
 		}
 	}
+	if b.outputFile != nil {
+		stdOutWriters = append(stdOutWriters[:len(stdOutWriters):len(stdOutWriters)], b.outputFile)
+	}
 
-	errorsFilePath := os.Getenv("GOAT_OUTPUT_ERRORS_FILE")
-
-	if errorsFilePath != "" {
-		if errorsFile, err := os.Create(errorsFilePath); err != nil {
-			fmt.Printf("failed to create errors file %s: %v, using stderr\n", errorsFilePath, err)
+	if b.errorsPath != "" && b.errorsFile == nil {
+		if errorsFile, err := os.Create(b.errorsPath); err != nil {
+			fmt.Printf("failed to create errors file %s: %v, using stderr\n", b.errorsPath, err)
 		} else {
 			b.errorsFile = errorsFile
-			stdErrWriters = append(stdErrWriters, errorsFile)
 		}
 	}
-
-	if getFieldsCollectorFilePath() != "" {
-		b.fieldsParser = newFieldsCollector()
-		stdOutWriters = append(stdOutWriters, b.fieldsParser)
+	if b.errorsFile != nil {
+		stdErrWriters = append(stdErrWriters[:len(stdErrWriters):len(stdErrWriters)], b.errorsFile)
 	}
 
-	cmd.Stdout = io.MultiWriter(stdOutWriters...)
-	cmd.Stderr = io.MultiWriter(stdErrWriters...)
+	b.cmd.Stdout = io.MultiWriter(stdOutWriters...)
+	b.cmd.Stderr = io.MultiWriter(stdErrWriters...)
+}
+
+// isolateOutputs suffixes output files with the name, so executors of parallel flows do not share them
+func (b *Executor) isolateOutputs(name string) {
+	b.outputPath = suffixPath(b.outputPath, name)
+	b.errorsPath = suffixPath(b.errorsPath, name)
+}
 
-	return b
+// suffixPath adds the suffix before the extension of the path, an empty path is kept
+func suffixPath(path, suffix string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + suffix + ext
 }
 
 func NewExecutor(binary string, envs map[string]string, args ...string) *Executor {
-	if strings.ToLower(os.Getenv("GOAT_REMOTE_DEBUG")) == TrueValue {
-		return debugExecutor(binary, envs, args...)
+	return newExecutor(binary, envs, executorConfigFromEnv(), args...)
+}
+
+func newExecutor(binary string, envs map[string]string, cfg executorConfig, args ...string) *Executor {
+	if cfg.debug {
+		return debugExecutor(binary, envs, cfg, args...)
 	}
-	return directExecutor(binary, envs, args...)
+	return directExecutor(binary, envs, cfg, args...)
 }
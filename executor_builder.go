@@ -1,14 +1,11 @@
 package goat
 
-import (
-	"os"
-)
-
 // ExecutorBuilder provides a fluent API for building Executor instances.
 //
 //nolint:govet // fieldalignment: struct optimization not worth the readability cost
 type ExecutorBuilder struct {
 	env           map[string]string
+	logFieldsOpts []LogFieldsOption
 	args          []string
 	binary        string
 	debugPort     string
@@ -81,6 +78,13 @@ func (b *ExecutorBuilder) WithFieldsFile(path string) *ExecutorBuilder {
 	return b
 }
 
+// WithLogFieldsOptions configures log field collection, e.g. the line parser and ignored lines.
+// It applies when log field validation is enabled.
+func (b *ExecutorBuilder) WithLogFieldsOptions(opts ...LogFieldsOption) *ExecutorBuilder {
+	b.logFieldsOpts = append(b.logFieldsOpts, opts...)
+	return b
+}
+
 // WithDisableStdout disables stdout output.
 func (b *ExecutorBuilder) WithDisableStdout(disable bool) *ExecutorBuilder {
 	b.disableStdout = disable
@@ -88,31 +92,34 @@ func (b *ExecutorBuilder) WithDisableStdout(disable bool) *ExecutorBuilder {
 }
 
 // Build creates the Executor with the configured options.
+// Options override GOAT_* variables for this executor only, the process env is not changed,
+// so executors of parallel flows can be configured differently.
 func (b *ExecutorBuilder) Build() *Executor {
-	// Set environment variables for configuration
+	cfg := executorConfigFromEnv()
 	if b.debug {
+		cfg.debug = true
 		if b.debugPort != "" {
-			os.Setenv("GOAT_REMOTE_DEBUG_PORT", b.debugPort)
+			cfg.debugPort = b.debugPort
 		}
-		os.Setenv("GOAT_REMOTE_DEBUG", TrueValue)
 	}
-
 	if b.disableStdout {
-		os.Setenv("GOAT_DISABLE_STDOUT", TrueValue)
+		cfg.disableStdout = true
 	}
-
 	if b.outputFile != "" {
-		os.Setenv("GOAT_OUTPUT_FILE", b.outputFile)
+		cfg.outputFile = b.outputFile
 	}
-
 	if b.errorsFile != "" {
-		os.Setenv("GOAT_OUTPUT_ERRORS_FILE", b.errorsFile)
+		cfg.errorsFile = b.errorsFile
 	}
-
 	if b.fieldsFile != "" {
-		os.Setenv("GOAT_LOG_FIELDS_FILE", b.fieldsFile)
+		cfg.fieldsFile = b.fieldsFile
 	}
 
-	// Build the executor using the existing constructor
-	return NewExecutor(b.binary, b.env, b.args...)
+	e := newExecutor(b.binary, b.env, cfg, b.args...)
+	if e.fieldsParser != nil {
+		for _, opt := range b.logFieldsOpts {
+			opt(e.fieldsParser)
+		}
+	}
+	return e
 }
@@ -1,6 +1,7 @@
 package goat
 
 import (
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -75,3 +76,21 @@ func TestExecutorRun(t *testing.T) {
 	e := NewExecutor("/bin/sh", nil, "-c", `i=0; while [ $i -lt 10 ]; do echo "hello"; i=$((i + 1)); done`)
 	require.NoError(t, e.Run())
 }
+
+func TestExecutorBuilderKeepsProcessEnv(t *testing.T) {
+	t.Setenv("GOAT_OUTPUT_FILE", "")
+	t.Setenv("GOAT_LOG_FIELDS_FILE", "")
+	dir := t.TempDir()
+	e := NewExecutorBuilder("/bin/sh").
+		WithArgs("-c", `echo '{"level":"info"}'`).
+		WithOutputFile(dir + "/out.log").
+		WithFieldsFile(dir + "/fields.csv").
+		WithDisableStdout(true).
+		Build()
+	require.NoError(t, e.Run())
+
+	require.Empty(t, os.Getenv("GOAT_OUTPUT_FILE"))
+	require.Empty(t, os.Getenv("GOAT_LOG_FIELDS_FILE"))
+	require.FileExists(t, dir+"/out.log")
+	require.Equal(t, LogFieldStats{Fields: map[string]string{"level": "string"}, File: dir + "/fields.csv"}, e.LogFieldStats())
+}
@@ -0,0 +1,338 @@
+package goat
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRateLimitWindow = time.Second
+	droppedBodyLength      = 1024
+
+	// failureDropConnection is the failure of HTTPFaults.decide closing the connection instead of a status
+	failureDropConnection = -1
+)
+
+type (
+	// HTTPFault describes failures injected into responses of a mock HTTP route.
+	//
+	// Failures are FailStatus responses or dropped connections if DropConnection is set.
+	// FailTimes limits failures to the first N requests, after that the request is handled by the mock;
+	// zero FailTimes fails every request if FailStatus or DropConnection is set.
+	HTTPFault struct {
+		// Delay is applied before the request is handled
+		Delay time.Duration
+		// Jitter adds a random delay in [0, Jitter)
+		Jitter time.Duration
+		// RateLimitWindow is the rate limit window, one second by default
+		RateLimitWindow time.Duration
+		// RetryAfter is sent in Retry-After header of 429 responses, RateLimitWindow by default
+		RetryAfter time.Duration
+		FailTimes  int
+		FailStatus int
+		// RateLimit allows only N requests per RateLimitWindow, other requests get 429
+		RateLimit int
+		// DropConnection closes the connection after sending headers and a part of the body
+		DropConnection bool
+	}
+
+	// GRPCFault describes failures injected into calls of a mock gRPC method.
+	// FailTimes limits failures to the first N calls, zero fails every call if Code is not OK.
+	GRPCFault struct {
+		// Trailer is sent with the failed status
+		Trailer   metadata.MD
+		Message   string
+		Delay     time.Duration
+		Jitter    time.Duration
+		FailTimes int
+		Code      codes.Code
+	}
+
+	// HTTPFaults holds faults of HTTP mock routes, it can be changed at runtime from the test.
+	// If several routes match a request, the one set first is applied.
+	HTTPFaults struct {
+		routes []*httpFaultState
+		m      sync.Mutex
+	}
+
+	// GRPCFaults holds faults of gRPC mock methods, it can be changed at runtime from the test.
+	// If several patterns match a method, the one set first is applied.
+	GRPCFaults struct {
+		methods []*grpcFaultState
+		m       sync.Mutex
+	}
+
+	httpFaultState struct {
+		windowStart time.Time
+		route       string
+		fault       HTTPFault
+		failed      int
+		inWindow    int
+	}
+
+	grpcFaultState struct {
+		method string
+		fault  GRPCFault
+		failed int
+	}
+)
+
+func newHTTPFaults() *HTTPFaults {
+	return &HTTPFaults{}
+}
+
+func newGRPCFaults() *GRPCFaults {
+	return &GRPCFaults{}
+}
+
+// Set sets the fault of the route. The route is a path pattern with optional method, e.g. "/v1/orders", "POST /v1/orders/*".
+// Patterns use path.Match syntax. Setting the route again replaces its fault and keeps its priority.
+func (f *HTTPFaults) Set(route string, fault HTTPFault) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	state := &httpFaultState{route: route, fault: fault}
+	for i, s := range f.routes {
+		if s.route == route {
+			f.routes[i] = state
+			return
+		}
+	}
+	f.routes = append(f.routes, state)
+}
+
+// Clear removes the fault of the route.
+func (f *HTTPFaults) Clear(route string) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.routes = slices.DeleteFunc(f.routes, func(s *httpFaultState) bool { return s.route == route })
+}
+
+// Reset removes all faults.
+func (f *HTTPFaults) Reset() {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.routes = nil
+}
+
+// decide returns the delay and the failure to apply to the request, failure is zero if the request must be handled.
+func (f *HTTPFaults) decide(r *http.Request) (delay time.Duration, fault HTTPFault, failure int, ok bool) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	state := f.match(r)
+	if state == nil {
+		return 0, HTTPFault{}, 0, false
+	}
+	fault = state.fault
+	delay = randomDelay(fault.Delay, fault.Jitter)
+
+	if fault.RateLimit > 0 {
+		window := fault.RateLimitWindow
+		if window <= 0 {
+			window = defaultRateLimitWindow
+		}
+		now := time.Now()
+		if now.Sub(state.windowStart) >= window {
+			state.windowStart = now
+			state.inWindow = 0
+		}
+		state.inWindow++
+		if state.inWindow > fault.RateLimit {
+			return delay, fault, http.StatusTooManyRequests, true
+		}
+	}
+
+	if fault.FailStatus == 0 && !fault.DropConnection {
+		return delay, fault, 0, true
+	}
+	if fault.FailTimes > 0 && state.failed >= fault.FailTimes {
+		return delay, fault, 0, true
+	}
+	state.failed++
+
+	if fault.DropConnection {
+		return delay, fault, failureDropConnection, true
+	}
+	return delay, fault, fault.FailStatus, true
+}
+
+func (f *HTTPFaults) match(r *http.Request) *httpFaultState {
+	for _, state := range f.routes {
+		method, pattern, found := strings.Cut(state.route, " ")
+		if !found {
+			method, pattern = "", state.route
+		}
+		if method != "" && method != r.Method {
+			continue
+		}
+		if matched, _ := path.Match(pattern, r.URL.Path); matched { //nolint:errcheck // bad pattern never matches
+			return state
+		}
+	}
+	return nil
+}
+
+func (f *HTTPFaults) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay, fault, failure, ok := f.decide(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !sleepContext(r.Context(), delay) {
+			return
+		}
+
+		switch failure {
+		case 0:
+			next.ServeHTTP(w, r)
+		case failureDropConnection:
+			dropConnection(w)
+		case http.StatusTooManyRequests:
+			retryAfter := fault.RetryAfter
+			if retryAfter <= 0 {
+				retryAfter = fault.RateLimitWindow
+			}
+			if retryAfter <= 0 {
+				retryAfter = defaultRateLimitWindow
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(failure)
+		}
+	})
+}
+
+func dropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	defer conn.Close()
+
+	_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: " + //nolint:errcheck // connection is dropped anyway
+		strconv.Itoa(droppedBodyLength) + "\r\n\r\n{\"")
+	_ = buf.Flush() //nolint:errcheck // connection is dropped anyway
+}
+
+// Set sets the fault of the method. The method is a full method name pattern, e.g. "/pkg.Service/Method", "/pkg.Service/*".
+// Setting the method again replaces its fault and keeps its priority.
+func (f *GRPCFaults) Set(method string, fault GRPCFault) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	state := &grpcFaultState{method: method, fault: fault}
+	for i, s := range f.methods {
+		if s.method == method {
+			f.methods[i] = state
+			return
+		}
+	}
+	f.methods = append(f.methods, state)
+}
+
+// Clear removes the fault of the method.
+func (f *GRPCFaults) Clear(method string) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.methods = slices.DeleteFunc(f.methods, func(s *grpcFaultState) bool { return s.method == method })
+}
+
+// Reset removes all faults.
+func (f *GRPCFaults) Reset() {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.methods = nil
+}
+
+func (f *GRPCFaults) decide(method string) (delay time.Duration, fault GRPCFault, fail bool) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	var state *grpcFaultState
+	for _, s := range f.methods {
+		if matched, _ := path.Match(s.method, method); matched { //nolint:errcheck // bad pattern never matches
+			state = s
+			break
+		}
+	}
+	if state == nil {
+		return 0, GRPCFault{}, false
+	}
+
+	fault = state.fault
+	delay = randomDelay(fault.Delay, fault.Jitter)
+	if fault.Code == codes.OK || (fault.FailTimes > 0 && state.failed >= fault.FailTimes) {
+		return delay, fault, false
+	}
+	state.failed++
+	return delay, fault, true
+}
+
+func (f *GRPCFaults) apply(ctx context.Context, method string, setTrailer func(md metadata.MD)) error {
+	delay, fault, fail := f.decide(method)
+	if !sleepContext(ctx, delay) {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if !fail {
+		return nil
+	}
+	if fault.Trailer != nil {
+		setTrailer(fault.Trailer)
+	}
+	return status.Error(fault.Code, fault.Message)
+}
+
+func (f *GRPCFaults) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := f.apply(ctx, info.FullMethod, func(md metadata.MD) {
+		_ = grpc.SetTrailer(ctx, md) //nolint:errcheck // trailer is best effort
+	}); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (f *GRPCFaults) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := f.apply(ss.Context(), info.FullMethod, ss.SetTrailer); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func randomDelay(delay, jitter time.Duration) time.Duration {
+	if jitter > 0 {
+		delay += rand.N(jitter) //nolint:gosec // no need for crypto rand in tests
+	}
+	return delay
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
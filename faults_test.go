@@ -0,0 +1,139 @@
+package goat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPFaults(t *testing.T) {
+	h, err := NewHTTPMockHandler("tcp", "127.0.0.1:0", func(server *http.ServeMux) {
+		server.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+	require.NoError(t, err)
+	go func() {
+		_ = h.Start()
+	}()
+	defer func() {
+		_ = h.Stop()
+	}()
+
+	get := func(path string) (*http.Response, error) {
+		rsp, getErr := http.Get("http://" + h.Addr() + path)
+		if getErr == nil {
+			_ = rsp.Body.Close()
+		}
+		return rsp, getErr
+	}
+
+	h.Faults().Set("GET /orders/*", HTTPFault{FailTimes: 2, FailStatus: http.StatusServiceUnavailable})
+	for _, expected := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		rsp, getErr := get("/orders/1")
+		require.NoError(t, getErr)
+		require.Equal(t, expected, rsp.StatusCode)
+	}
+
+	h.Faults().Set("/limited", HTTPFault{RateLimit: 1, RateLimitWindow: time.Minute, RetryAfter: 3 * time.Second})
+	rsp, err := get("/limited")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	rsp, err = get("/limited")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, rsp.StatusCode)
+	require.Equal(t, "3", rsp.Header.Get("Retry-After"))
+
+	h.Faults().Set("/drop", HTTPFault{DropConnection: true, FailTimes: 1})
+	rsp, err = http.Get("http://" + h.Addr() + "/drop")
+	if err == nil {
+		_, err = io.ReadAll(rsp.Body)
+		_ = rsp.Body.Close()
+	}
+	require.Error(t, err)
+	rsp, err = get("/drop")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	// overlapping routes are matched in the order they are set
+	h.Faults().Reset()
+	h.Faults().Set("/items/*", HTTPFault{FailStatus: http.StatusBadGateway})
+	h.Faults().Set("GET /items/*", HTTPFault{FailStatus: http.StatusGatewayTimeout})
+	for range 10 {
+		rsp, err = get("/items/1")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, rsp.StatusCode)
+	}
+	h.Faults().Clear("/items/*")
+	rsp, err = get("/items/1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusGatewayTimeout, rsp.StatusCode)
+
+	h.Faults().Reset()
+	h.Faults().Set("/slow", HTTPFault{Delay: 100 * time.Millisecond})
+	started := time.Now()
+	_, err = get("/slow")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(started), 100*time.Millisecond)
+}
+
+func TestGRPCFaults(t *testing.T) {
+	h, err := NewGRPCMockHandler("tcp", "127.0.0.1:0", func(server *grpc.Server) {
+		healthpb.RegisterHealthServer(server, health.NewServer())
+	})
+	require.NoError(t, err)
+	go func() {
+		_ = h.Start()
+	}()
+	defer func() {
+		_ = h.Stop()
+	}()
+
+	conn, err := grpc.NewClient(h.Addr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h.Faults().Set("/grpc.health.v1.Health/*", GRPCFault{
+		Code:      codes.Unavailable,
+		Message:   "try later",
+		FailTimes: 1,
+		Trailer:   metadata.Pairs("retry-after", "1"),
+	})
+
+	var trailer metadata.MD
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, []string{"1"}, trailer.Get("retry-after"))
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	calls := h.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, codes.Unavailable, calls[0].Code)
+
+	h.Faults().Reset()
+	h.Faults().Set("/grpc.health.v1.Health/Check", GRPCFault{Code: codes.NotFound})
+	h.Faults().Set("/grpc.health.v1.Health/*", GRPCFault{Code: codes.Internal})
+	// setting the pattern again keeps its priority
+	h.Faults().Set("/grpc.health.v1.Health/Check", GRPCFault{Code: codes.PermissionDenied})
+	for range 10 {
+		_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	}
+}
@@ -1,53 +1,143 @@
 package goat
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+// Flow runs the app with its mock servers. Start registers Stop with t.Cleanup,
+// calling Stop explicitly is only needed to run hooks or to stop the app earlier.
 type Flow struct {
 	mocks *MocksHandler
 	app   BaseExecutor
 	env   *Env
+	// started and stopped make Stop idempotent and safe after a failed Start
+	started    bool
+	appStarted bool
+	stopped    bool
+	m          sync.Mutex
 }
 
-func NewFlow(t *testing.T, env *Env, exe BaseExecutor, hcb HTTPCB, gCb GrpcCB) *Flow {
+// AppFactory creates the app under test once mock servers are listening,
+// so the app env can be built from resolved mock addresses.
+type AppFactory func(mocks *MocksHandler) BaseExecutor
+
+func NewFlow(t *testing.T, env *Env, exe BaseExecutor, hcb HTTPCB, gCb GrpcCB, opts ...MocksOption) *Flow {
 	t.Helper()
 	return &Flow{
 		env:   env,
-		mocks: NewMocksHandler(t, gCb, hcb),
+		mocks: NewMocksHandler(t, gCb, hcb, opts...),
 		app:   exe,
 	}
 }
 
+// NewFlowWithApp creates a flow whose app is built from the mock servers, e.g.
+//
+//	flow := gtt.NewFlowWithApp(t, env, func(mocks *gtt.MocksHandler) gtt.BaseExecutor {
+//		return gtt.NewExecutorBuilder(binary).
+//			WithEnvVar("PAYMENTS_URL", "http://"+mocks.Addr("payments")).
+//			Build()
+//	}, nil, nil, gtt.WithHTTPMock("payments", paymentsCB))
+func NewFlowWithApp(t *testing.T, env *Env, app AppFactory, hcb HTTPCB, gCb GrpcCB, opts ...MocksOption) *Flow {
+	t.Helper()
+	mocks := NewMocksHandler(t, gCb, hcb, opts...)
+	return &Flow{
+		env:   env,
+		mocks: mocks,
+		app:   app(mocks),
+	}
+}
+
+// NewParallelFlow creates and starts a flow for tests running with t.Parallel.
+// Every flow has its own mock servers on random ports with its own gomock controller and its own app,
+// built by the factory, e.g. with a separate database schema. The flow is stopped by t.Cleanup of Start.
+// GOAT_OUTPUT_FILE and GOAT_OUTPUT_ERRORS_FILE of an Executor are suffixed with the test name,
+// debug mode is refused as parallel apps would share the dlv port.
+//
+//	func TestOrders(t *testing.T) {
+//		t.Parallel()
+//		flow := gtt.NewParallelFlow(t, env, func(mocks *gtt.MocksHandler) gtt.BaseExecutor {
+//			return gtt.NewExecutorBuilder(binary).
+//				WithEnvVar("PAYMENTS_URL", "http://"+mocks.Addr("payments")).
+//				WithEnvVar("DB_SCHEMA", "orders").
+//				Build()
+//		}, nil, nil, gtt.WithHTTPMock("payments", paymentsCB))
+//		...
+//	}
+func NewParallelFlow(t *testing.T, env *Env, app AppFactory, hcb HTTPCB, gCb GrpcCB, opts ...MocksOption) *Flow {
+	t.Helper()
+	// the caller's backing array is not written
+	opts = append(opts[:len(opts):len(opts)], WithRandomPorts())
+	f := NewFlowWithApp(t, env, app, hcb, gCb, opts...)
+	if f.app.IsDebug() {
+		f.mocks.Stop()
+		t.Fatal("debug mode is not supported by parallel flows, run the test alone with NewFlowWithApp")
+	}
+	if e, ok := f.app.(*Executor); ok {
+		e.isolateOutputs(artifactName(t.Name()))
+	}
+	f.Start(t, nil, nil)
+	return f
+}
+
+// Mocks returns the mock servers of the flow.
+func (f *Flow) Mocks() *MocksHandler {
+	return f.mocks
+}
+
 func (f *Flow) Start(t *testing.T, before, after func(env *Env) error) {
 	if before != nil {
 		require.NoError(t, before(f.env))
 	}
 
+	f.m.Lock()
+	f.started = true
+	f.m.Unlock()
+	t.Cleanup(func() {
+		f.Stop(t, nil, nil)
+	})
+
 	f.mocks.Start(t)
 	require.NoError(t, f.app.Start(), "failed to run app")
+	f.env.trackExecutor(f.app)
+	f.m.Lock()
+	f.appStarted = true
+	f.m.Unlock()
 
 	if after != nil {
 		require.NoError(t, after(f.env))
 	}
 }
 
+// Stop stops mock servers and the app, it does nothing if the flow is not started or already stopped.
 func (f *Flow) Stop(t *testing.T, before, after func(env *Env) error) {
+	f.m.Lock()
+	if !f.started || f.stopped {
+		f.m.Unlock()
+		return
+	}
+	f.stopped = true
+	appStarted := f.appStarted
+	f.m.Unlock()
+
 	if before != nil {
 		require.NoError(t, before(f.env))
 	}
 
 	f.mocks.Stop()
+	if !appStarted {
+		return
+	}
+	f.env.untrackExecutor(f.app)
 	require.NoError(t, f.app.Stop(), "failed to stop app")
-	_ = f.app.Stop()
 
 	if after != nil {
 		require.NoError(t, after(f.env))
 	}
 
-	if executor, ok := f.app.(*Executor); ok && executor.fieldsParser != nil {
-		f.env.mergeLogFieldStats(executor.fieldsParser.fields, executor.fieldsParser.unmarshalErrors)
+	if reporter, ok := f.app.(LogFieldsReporter); ok {
+		f.env.mergeLogFieldStats(reporter.LogFieldStats())
 	}
 }
@@ -0,0 +1,151 @@
+package goat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestParallelFlows(t *testing.T) {
+	// a fixed address would make parallel flows collide
+	t.Setenv("GOAT_HTTP_MOCK_ADDRESS", "127.0.0.1:1")
+
+	env := &Env{}
+	apps := make([]*fakeExecutor, 4)
+	t.Run("group", func(t *testing.T) {
+		for i := range apps {
+			apps[i] = &fakeExecutor{}
+			t.Run(fmt.Sprint(i), func(t *testing.T) {
+				t.Parallel()
+				name := t.Name()
+				flow := NewParallelFlow(t, env, func(*MocksHandler) BaseExecutor {
+					return apps[i]
+				}, func(server *http.ServeMux, _ *gomock.Controller) {
+					server.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+						_, _ = w.Write([]byte(name))
+					})
+				}, nil)
+
+				rsp, err := http.Get("http://" + flow.Mocks().Addr(DefaultHTTPMockName) + "/")
+				require.NoError(t, err)
+				body, err := io.ReadAll(rsp.Body)
+				require.NoError(t, err)
+				require.NoError(t, rsp.Body.Close())
+				require.Equal(t, name, string(body))
+			})
+		}
+	})
+
+	for _, app := range apps {
+		require.True(t, app.stopped.Load(), "app is stopped by t.Cleanup")
+	}
+	require.Empty(t, env.executors)
+}
+
+func TestParallelFlowsOutputFiles(t *testing.T) {
+	t.Setenv("GOAT_HTTP_MOCK_ADDRESS", "127.0.0.1:1")
+	t.Setenv("GOAT_LOG_FIELDS_FILE", "")
+	dir := t.TempDir()
+	t.Setenv("GOAT_OUTPUT_FILE", filepath.Join(dir, "out.log"))
+
+	opts := make([]MocksOption, 0, 2)
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"a", "b"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				NewParallelFlow(t, &Env{}, func(*MocksHandler) BaseExecutor {
+					return NewExecutorBuilder("/bin/sh").
+						WithArgs("-c", "trap 'exit 0' TERM; echo "+name+"; while :; do sleep 0.1; done").
+						WithDisableStdout(true).
+						Build()
+				}, nil, nil, opts...)
+
+				path := filepath.Join(dir, "out-TestParallelFlowsOutputFiles_group_"+name+".log")
+				require.Eventually(t, func() bool {
+					data, err := os.ReadFile(path)
+					return err == nil && string(data) == name+"\n"
+				}, 5*time.Second, 10*time.Millisecond)
+			})
+		}
+	})
+
+	require.NoFileExists(t, filepath.Join(dir, "out.log"))
+	require.Empty(t, opts[:cap(opts)][0], "options of the caller are not written")
+}
+
+func TestFlowStopIsIdempotent(t *testing.T) {
+	env := &Env{}
+	app := &fakeExecutor{}
+	t.Run("flow", func(t *testing.T) {
+		flow := NewFlow(t, env, app, nil, nil)
+		flow.Stop(t, nil, nil) // not started
+		require.False(t, app.stopped.Load())
+
+		flow.Start(t, nil, nil)
+		flow.Stop(t, nil, nil)
+		require.True(t, app.stopped.Load())
+		app.stopped.Store(false)
+	})
+	require.False(t, app.stopped.Load(), "t.Cleanup does not stop the flow again")
+	require.Empty(t, env.executors)
+}
+
+func TestSharedFlow(t *testing.T) {
+	var (
+		notifier *notifierMock
+		status   *health.Server
+	)
+	app := &fakeExecutor{}
+	flow := NewSharedFlow(t, &Env{}, func(*MocksHandler) BaseExecutor { return app },
+		func(server *http.ServeMux, ctl *gomock.Controller) {
+			n := &notifierMock{ctrl: ctl}
+			notifier = n
+			server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				n.Notify(strings.TrimPrefix(r.URL.Path, "/"))
+			})
+		},
+		func(server *grpc.Server, _ *gomock.Controller) {
+			status = health.NewServer()
+			healthpb.RegisterHealthServer(server, status)
+		})
+
+	conn, err := grpc.NewClient(flow.Mocks().Addr(DefaultGRPCMockName), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	parent := flow.Mocks().Controller()
+	for _, name := range []string{"first", "second"} {
+		flow.Run(t, name, func(t *testing.T, mocks *MocksHandler) {
+			require.Equal(t, t, mocks.Controller().T, "expectations are reported to the subtest")
+
+			notifier.expectNotify(name)
+			rsp, err := http.Get("http://" + mocks.Addr(DefaultHTTPMockName) + "/" + name)
+			require.NoError(t, err)
+			require.NoError(t, rsp.Body.Close())
+
+			// the client reconnects to the server registered for the subtest
+			status.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: name})
+			require.NoError(t, err)
+		})
+	}
+
+	require.Same(t, parent, flow.Mocks().Controller(), "mocks are bound to the parent controller between subtests")
+	require.False(t, app.stopped.Load())
+}
@@ -0,0 +1,285 @@
+package goat
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultTraceHeaders are the metadata keys checked by AssertGRPCTraceHeaders when no keys are given.
+var DefaultTraceHeaders = []string{"traceparent"}
+
+// GRPCCall is a journal record of a single call received by GRPCMockHandler.
+//
+//nolint:govet // fieldalignment: struct optimization not worth the readability cost
+type GRPCCall struct {
+	// Method is the full method name, e.g. "/pkg.Service/Method"
+	Method string
+	// Metadata is the incoming metadata sent by the client
+	Metadata metadata.MD
+	// Deadline is the call deadline, valid only if HasDeadline is true
+	Deadline    time.Time
+	HasDeadline bool
+	// IsStream is true for streaming calls
+	IsStream bool
+	// PeerCertificate is the leaf certificate presented by the client over mTLS
+	PeerCertificate *x509.Certificate
+	// Requests are the messages received from the client
+	Requests []interface{}
+	// Responses are the messages sent to the client
+	Responses []interface{}
+	// Code is the status code returned to the client
+	Code codes.Code
+	// Err is the error returned by the handler, nil on success
+	Err       error
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+type grpcJournal struct {
+	calls []*GRPCCall
+	m     sync.Mutex
+}
+
+type recordingServerStream struct {
+	grpc.ServerStream
+	journal *grpcJournal
+	call    *GRPCCall
+}
+
+// MetadataValue returns the first value of the metadata key or empty string.
+func (c *GRPCCall) MetadataValue(key string) string {
+	values := c.Metadata.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c *GRPCCall) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("-----------------\n")
+	buf.WriteString(fmt.Sprintf("grpc call: %s\n", c.Method))
+
+	for k, v := range c.Metadata {
+		buf.WriteString(fmt.Sprintf("	%s: %s\n", k, v))
+	}
+	if c.HasDeadline {
+		buf.WriteString(fmt.Sprintf("deadline: %s\n", c.Deadline.Format(time.RFC3339Nano)))
+	}
+	for _, req := range c.Requests {
+		buf.WriteString(fmt.Sprintf("req: %s\n", truncateBody(fmt.Sprint(req))))
+	}
+	buf.WriteString("response:\n")
+	buf.WriteString(fmt.Sprintf("status: %s\n", c.Code))
+	if c.Err != nil {
+		buf.WriteString(fmt.Sprintf("error: %s\n", c.Err))
+	}
+	for _, rsp := range c.Responses {
+		buf.WriteString(fmt.Sprintf("rsp: %s\n", truncateBody(fmt.Sprint(rsp))))
+	}
+	buf.WriteString(fmt.Sprintf("duration: %s\n", c.Duration))
+
+	return buf.String()
+}
+
+func truncateBody(s string) string {
+	if len(s) > bodySizeLimit {
+		return s[:bodySizeLimit] + "..."
+	}
+	return s
+}
+
+func newGRPCJournal() *grpcJournal {
+	return &grpcJournal{}
+}
+
+func (j *grpcJournal) begin(ctx context.Context, method string, isStream bool) *GRPCCall {
+	call := &GRPCCall{
+		Method:    method,
+		IsStream:  isStream,
+		StartedAt: time.Now(),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		call.Metadata = md.Copy()
+	}
+	call.Deadline, call.HasDeadline = ctx.Deadline()
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+			call.PeerCertificate = tlsInfo.State.PeerCertificates[0]
+		}
+	}
+
+	j.m.Lock()
+	j.calls = append(j.calls, call)
+	j.m.Unlock()
+
+	return call
+}
+
+func (j *grpcJournal) addRequest(call *GRPCCall, msg interface{}) {
+	j.m.Lock()
+	defer j.m.Unlock()
+	call.Requests = append(call.Requests, msg)
+}
+
+func (j *grpcJournal) addResponse(call *GRPCCall, msg interface{}) {
+	j.m.Lock()
+	defer j.m.Unlock()
+	call.Responses = append(call.Responses, msg)
+}
+
+func (j *grpcJournal) finish(call *GRPCCall, err error) {
+	j.m.Lock()
+	call.Err = err
+	call.Code = status.Code(err)
+	call.Duration = time.Since(call.StartedAt)
+	j.m.Unlock()
+
+	if strings.ToLower(os.Getenv("GOAT_GRPC_DEBUG")) == TrueValue {
+		fmt.Println(j.snapshot(call).String())
+	}
+}
+
+func (j *grpcJournal) snapshot(call *GRPCCall) *GRPCCall {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	c := copyCall(call)
+	return &c
+}
+
+func copyCall(call *GRPCCall) GRPCCall {
+	c := *call
+	c.Requests = append([]interface{}(nil), call.Requests...)
+	c.Responses = append([]interface{}(nil), call.Responses...)
+	return c
+}
+
+func (j *grpcJournal) list(method string) []GRPCCall {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	result := make([]GRPCCall, 0, len(j.calls))
+	for _, call := range j.calls {
+		if method != "" && call.Method != method {
+			continue
+		}
+		result = append(result, copyCall(call))
+	}
+	return result
+}
+
+func (j *grpcJournal) reset() {
+	j.m.Lock()
+	defer j.m.Unlock()
+	j.calls = nil
+}
+
+func (j *grpcJournal) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	call := j.begin(ctx, info.FullMethod, false)
+	j.addRequest(call, req)
+
+	resp, err := handler(ctx, req)
+	if err == nil {
+		j.addResponse(call, resp)
+	}
+	j.finish(call, err)
+
+	return resp, err
+}
+
+func (j *grpcJournal) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	call := j.begin(ss.Context(), info.FullMethod, true)
+
+	err := handler(srv, &recordingServerStream{
+		ServerStream: ss,
+		journal:      j,
+		call:         call,
+	})
+	j.finish(call, err)
+
+	return err
+}
+
+func (s *recordingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.journal.addRequest(s.call, m)
+	return nil
+}
+
+func (s *recordingServerStream) SendMsg(m interface{}) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.journal.addResponse(s.call, m)
+	return nil
+}
+
+// Calls returns all calls received by the server in arrival order.
+func (h *GRPCMockHandler) Calls() []GRPCCall {
+	return h.journal.list("")
+}
+
+// CallsTo returns calls of the given full method name, e.g. "/pkg.Service/Method".
+func (h *GRPCMockHandler) CallsTo(method string) []GRPCCall {
+	return h.journal.list(method)
+}
+
+// ResetCalls clears the call journal.
+func (h *GRPCMockHandler) ResetCalls() {
+	h.journal.reset()
+}
+
+// RequireGRPCCall returns the last call of the method and fails the test if there were no such calls.
+func RequireGRPCCall(t testing.TB, h *GRPCMockHandler, method string) GRPCCall {
+	t.Helper()
+	calls := h.CallsTo(method)
+	require.NotEmpty(t, calls, "no grpc calls to %s", method)
+	return calls[len(calls)-1]
+}
+
+// AssertGRPCMetadata checks that the call has the metadata key with exactly the expected values.
+func AssertGRPCMetadata(t testing.TB, call GRPCCall, key string, expected ...string) bool {
+	t.Helper()
+	return assert.Equal(t, expected, call.Metadata.Get(key), "grpc call %s: metadata %q mismatch", call.Method, key)
+}
+
+// AssertGRPCAuthToken checks that the call has "authorization: Bearer <token>" metadata.
+func AssertGRPCAuthToken(t testing.TB, call GRPCCall, token string) bool {
+	t.Helper()
+	return AssertGRPCMetadata(t, call, "authorization", "Bearer "+token)
+}
+
+// AssertGRPCTraceHeaders checks that the call carries non-empty trace propagation metadata.
+// DefaultTraceHeaders are checked if no keys are given.
+func AssertGRPCTraceHeaders(t testing.TB, call GRPCCall, keys ...string) bool {
+	t.Helper()
+	if len(keys) == 0 {
+		keys = DefaultTraceHeaders
+	}
+	ok := true
+	for _, key := range keys {
+		if call.MetadataValue(key) == "" {
+			ok = assert.Fail(t, "trace header is missing", "grpc call %s: metadata %q is not propagated", call.Method, key)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,56 @@
+package goat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPCMockHandlerJournal(t *testing.T) {
+	h, err := NewGRPCMockHandler("tcp", "127.0.0.1:0", func(server *grpc.Server) {
+		healthpb.RegisterHealthServer(server, health.NewServer())
+	})
+	require.NoError(t, err)
+	go func() {
+		_ = h.Start()
+	}()
+	defer func() {
+		_ = h.Stop()
+	}()
+
+	conn, err := grpc.NewClient(h.Addr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret", "traceparent", "00-abc-def-01")
+
+	client := healthpb.NewHealthClient(conn)
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	require.Error(t, err)
+
+	calls := h.CallsTo(healthpb.Health_Check_FullMethodName)
+	require.Len(t, calls, 2)
+	require.Equal(t, codes.OK, calls[0].Code)
+	require.True(t, calls[0].HasDeadline)
+	require.Len(t, calls[0].Responses, 1)
+	require.Equal(t, codes.NotFound, calls[1].Code)
+
+	call := RequireGRPCCall(t, h, healthpb.Health_Check_FullMethodName)
+	AssertGRPCAuthToken(t, call, "secret")
+	AssertGRPCTraceHeaders(t, call)
+
+	h.ResetCalls()
+	require.Empty(t, h.Calls())
+}
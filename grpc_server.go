@@ -3,19 +3,48 @@ package goat
 import (
 	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 )
 
+// rebindGracePeriod is how long calls in flight are waited for when the server is replaced
+const rebindGracePeriod = time.Second
+
 type GRPCMockHandler struct {
 	server   *grpc.Server
 	listener net.Listener
+	journal  *grpcJournal
+	faults   *GRPCFaults
+	// conns feeds accepted connections to the current server, it is replaced by rebind
+	conns *connListener
+	opts  []grpc.ServerOption
+	m     sync.Mutex
+}
+
+// connListener is a listener of connections accepted by GRPCMockHandler for one server
+type connListener struct {
+	addr   net.Addr
+	conns  chan net.Conn
+	done   chan struct{}
+	closed sync.Once
 }
 
-func NewGRPCMockHandler(schema, address string, cb func(server *grpc.Server)) (*GRPCMockHandler, error) {
+// NewGRPCMockHandler creates a gRPC mock server listening on the address.
+// Every call is recorded in the journal available via Calls and may be failed via Faults;
+// additional server options are applied after the built-in interceptors.
+func NewGRPCMockHandler(schema, address string, cb func(server *grpc.Server), opts ...grpc.ServerOption) (*GRPCMockHandler, error) {
 	h := &GRPCMockHandler{
-		server: grpc.NewServer(),
+		journal: newGRPCJournal(),
+		faults:  newGRPCFaults(),
 	}
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(h.journal.unaryInterceptor, h.faults.unaryInterceptor),
+		grpc.ChainStreamInterceptor(h.journal.streamInterceptor, h.faults.streamInterceptor),
+	}, opts...)
+	h.opts = opts
+	h.server = grpc.NewServer(opts...)
 	cb(h.server)
 	grpcListen, err := net.Listen(schema, address)
 	if err != nil {
@@ -25,10 +54,125 @@ func NewGRPCMockHandler(schema, address string, cb func(server *grpc.Server)) (*
 	return h, nil
 }
 
+// Addr returns the resolved listen address.
+func (h *GRPCMockHandler) Addr() string {
+	return h.listener.Addr().String()
+}
+
+// Faults returns fault injection settings of the server.
+func (h *GRPCMockHandler) Faults() *GRPCFaults {
+	return h.faults
+}
+
 func (h *GRPCMockHandler) Start() error {
-	return h.server.Serve(h.listener)
+	h.m.Lock()
+	h.serve(h.server)
+	h.m.Unlock()
+
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			h.m.Lock()
+			_ = h.conns.Close()
+			h.m.Unlock()
+			return err
+		}
+		h.dispatch(conn)
+	}
 }
 
 func (h *GRPCMockHandler) Stop() error {
 	return h.listener.Close()
 }
+
+// rebind replaces the server with a new one with services registered by cb.
+// Services of a running grpc.Server can't be replaced, so the old server is stopped gracefully:
+// clients get GOAWAY and reconnect to the new one without backoff, calls in flight are finished
+// within rebindGracePeriod.
+func (h *GRPCMockHandler) rebind(cb func(server *grpc.Server)) {
+	server := grpc.NewServer(h.opts...)
+	cb(server)
+
+	h.m.Lock()
+	old, oldConns := h.server, h.conns
+	h.server = server
+	if oldConns != nil {
+		// the server is started, the new one takes over accepted connections
+		h.serve(server)
+	}
+	h.m.Unlock()
+
+	if oldConns != nil {
+		_ = oldConns.Close()
+		stopped := make(chan struct{})
+		go func() {
+			old.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(rebindGracePeriod):
+			// long-lived streams are cut, they must not call mocks of the previous controller
+			old.Stop()
+		}
+	}
+}
+
+// serve starts the server on a new connListener, h.m must be held
+func (h *GRPCMockHandler) serve(server *grpc.Server) {
+	conns := &connListener{
+		addr:  h.listener.Addr(),
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	h.conns = conns
+	go func() {
+		_ = server.Serve(conns) //nolint:errcheck // stopped by rebind or Stop
+	}()
+}
+
+// dispatch hands the connection to the current server, retrying if the server is replaced meanwhile
+func (h *GRPCMockHandler) dispatch(conn net.Conn) {
+	var prev *connListener
+	for {
+		h.m.Lock()
+		conns := h.conns
+		h.m.Unlock()
+		if conns == prev {
+			_ = conn.Close()
+			return
+		}
+		if conns.push(conn) {
+			return
+		}
+		prev = conns
+	}
+}
+
+// push hands the connection to the server, false if the listener is closed
+func (l *connListener) push(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closed.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
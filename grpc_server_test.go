@@ -0,0 +1,75 @@
+package goat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestGRPCMockHandlerRebind(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	register := func(server *grpc.Server) {
+		server.RegisterService(&grpc.ServiceDesc{
+			ServiceName: "goat.Slow",
+			HandlerType: (*interface{})(nil),
+			Methods: []grpc.MethodDesc{{
+				MethodName: "Wait",
+				Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+					if err := dec(new(emptypb.Empty)); err != nil {
+						return nil, err
+					}
+					started <- struct{}{}
+					<-release
+					return new(emptypb.Empty), nil
+				},
+			}},
+		}, struct{}{})
+	}
+
+	h, err := NewGRPCMockHandler("tcp", "127.0.0.1:0", register)
+	require.NoError(t, err)
+	go func() {
+		_ = h.Start()
+	}()
+	defer func() {
+		_ = h.Stop()
+	}()
+
+	conn, err := grpc.NewClient(h.Addr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	invoke := func() error {
+		return conn.Invoke(ctx, "/goat.Slow/Wait", new(emptypb.Empty), new(emptypb.Empty), grpc.WaitForReady(true))
+	}
+
+	inFlight := make(chan error, 1)
+	go func() {
+		inFlight <- invoke()
+	}()
+	<-started
+
+	rebound := make(chan struct{})
+	go func() {
+		h.rebind(register)
+		close(rebound)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-inFlight, "the call in flight is finished by the old server")
+	<-rebound
+
+	go func() {
+		<-started
+	}()
+	require.NoError(t, invoke(), "the client reconnects to the new server")
+}
@@ -0,0 +1,144 @@
+package goat
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPRequest is a journal record of a single request received by HTTPMockHandler.
+//
+//nolint:govet // fieldalignment: struct optimization not worth the readability cost
+type HTTPRequest struct {
+	Method string
+	// URI is the request URI sent by the client, e.g. "/orders?id=1"
+	URI    string
+	Header http.Header
+	Body   []byte
+	// Status is the status code sent to the client, 0 if the connection was hijacked or dropped
+	Status    int
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+type httpJournal struct {
+	requests []*HTTPRequest
+	m        sync.Mutex
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *HTTPRequest) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("-----------------\n")
+	buf.WriteString(fmt.Sprintf("request: %s %s\n", r.Method, r.URI))
+
+	for k, v := range r.Header {
+		buf.WriteString(fmt.Sprintf("	%s: %s\n", k, v))
+	}
+	if len(r.Body) > 0 {
+		buf.WriteString(fmt.Sprintf("req body: %s\n", truncateBody(string(r.Body))))
+	}
+	buf.WriteString(fmt.Sprintf("status: %d\n", r.Status))
+	buf.WriteString(fmt.Sprintf("duration: %s\n", r.Duration))
+
+	return buf.String()
+}
+
+func newHTTPJournal() *httpJournal {
+	return &httpJournal{}
+}
+
+func (j *httpJournal) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &HTTPRequest{
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Header:    r.Header.Clone(),
+			StartedAt: time.Now(),
+		}
+		data, _ := io.ReadAll(r.Body) //nolint:errcheck
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		req.Body = data
+
+		j.m.Lock()
+		j.requests = append(j.requests, req)
+		j.m.Unlock()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			j.m.Lock()
+			req.Status = rec.status
+			req.Duration = time.Since(req.StartedAt)
+			j.m.Unlock()
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+func (j *httpJournal) list() []HTTPRequest {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	result := make([]HTTPRequest, 0, len(j.requests))
+	for _, req := range j.requests {
+		result = append(result, *req)
+	}
+	return result
+}
+
+func (j *httpJournal) reset() {
+	j.m.Lock()
+	defer j.m.Unlock()
+	j.requests = nil
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming mocks (SSE) work with the journal.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket mocks work with the journal.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Requests returns all requests received by the server in arrival order.
+func (h *HTTPMockHandler) Requests() []HTTPRequest {
+	return h.journal.list()
+}
+
+// ResetRequests clears the request journal.
+func (h *HTTPMockHandler) ResetRequests() {
+	h.journal.reset()
+}
@@ -0,0 +1,52 @@
+package goat
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPJournal(t *testing.T) {
+	h, err := NewHTTPMockHandler("tcp", "127.0.0.1:0", func(server *http.ServeMux) {
+		server.HandleFunc("POST /orders", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(body)
+		})
+	})
+	require.NoError(t, err)
+	go func() {
+		_ = h.Start()
+	}()
+	defer func() {
+		_ = h.Stop()
+	}()
+
+	rsp, err := http.Post("http://"+h.Addr()+"/orders?source=web", "application/json", strings.NewReader(`{"id":1}`))
+	require.NoError(t, err)
+	body, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	_ = rsp.Body.Close()
+	require.Equal(t, `{"id":1}`, string(body), "the handler reads the body recorded by the journal")
+
+	h.Faults().Set("/missing", HTTPFault{FailTimes: 1, FailStatus: http.StatusServiceUnavailable})
+	rsp, err = http.Get("http://" + h.Addr() + "/missing")
+	require.NoError(t, err)
+	_ = rsp.Body.Close()
+
+	requests := h.Requests()
+	require.Len(t, requests, 2)
+	require.Equal(t, http.MethodPost, requests[0].Method)
+	require.Equal(t, "/orders?source=web", requests[0].URI)
+	require.Equal(t, "application/json", requests[0].Header.Get("Content-Type"))
+	require.Equal(t, `{"id":1}`, string(requests[0].Body))
+	require.Equal(t, http.StatusCreated, requests[0].Status)
+	require.Equal(t, http.StatusServiceUnavailable, requests[1].Status, "faults are recorded too")
+	require.Contains(t, requests[0].String(), "request: POST /orders?source=web\n")
+
+	h.ResetRequests()
+	require.Empty(t, h.Requests())
+}
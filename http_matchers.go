@@ -0,0 +1,160 @@
+package goat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type (
+	// HTTPRequestMatcher implements gomock.Matcher and gomock.GotFormatter for *http.Request.
+	// The body is read once and restored, so the request can be matched several times and handled after matching.
+	//
+	// Example:
+	//
+	//	handler.EXPECT().ServeHTTP(gomock.Any(), gtt.MatchRequest().
+	//		Method(http.MethodPost).
+	//		Path("/v1/orders/*").
+	//		Header("Authorization", "Bearer token").
+	//		JSONBody(gtt.JSONSubset(`{"sku":"A-1"}`)))
+	HTTPRequestMatcher struct {
+		checks []requestCheck
+	}
+
+	requestCheck struct {
+		// check returns an empty string if the request matches, otherwise a mismatch description
+		check       func(r *http.Request, body []byte) string
+		description string
+	}
+)
+
+// MatchRequest returns an empty request matcher, it matches any request.
+func MatchRequest() *HTTPRequestMatcher {
+	return &HTTPRequestMatcher{}
+}
+
+func (m *HTTPRequestMatcher) add(description string, check func(r *http.Request, body []byte) string) *HTTPRequestMatcher {
+	m.checks = append(m.checks, requestCheck{description: description, check: check})
+	return m
+}
+
+// Method checks the request method.
+func (m *HTTPRequestMatcher) Method(method string) *HTTPRequestMatcher {
+	return m.add("method "+method, func(r *http.Request, _ []byte) string {
+		if r.Method != method {
+			return fmt.Sprintf("method is %s, want %s", r.Method, method)
+		}
+		return ""
+	})
+}
+
+// Path checks the URL path with a path.Match pattern, e.g. "/v1/orders/*".
+func (m *HTTPRequestMatcher) Path(pattern string) *HTTPRequestMatcher {
+	return m.add("path "+pattern, func(r *http.Request, _ []byte) string {
+		if matched, _ := path.Match(pattern, r.URL.Path); !matched { //nolint:errcheck // bad pattern never matches
+			return fmt.Sprintf("path is %s, want %s", r.URL.Path, pattern)
+		}
+		return ""
+	})
+}
+
+// Header checks that the header has the value.
+func (m *HTTPRequestMatcher) Header(key, value string) *HTTPRequestMatcher {
+	return m.add(fmt.Sprintf("header %s=%s", key, value), func(r *http.Request, _ []byte) string {
+		values := r.Header.Values(key)
+		for _, v := range values {
+			if v == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("header %s is %q, want %q", key, values, value)
+	})
+}
+
+// Query checks that the query parameter has the value.
+func (m *HTTPRequestMatcher) Query(key, value string) *HTTPRequestMatcher {
+	return m.add(fmt.Sprintf("query %s=%s", key, value), func(r *http.Request, _ []byte) string {
+		values := r.URL.Query()[key]
+		for _, v := range values {
+			if v == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("query %s is %q, want %q", key, values, value)
+	})
+}
+
+// JSONBody checks the request body with the JSON matcher, e.g. JSONEq or JSONSubset.
+func (m *HTTPRequestMatcher) JSONBody(jm *JSONMatcher) *HTTPRequestMatcher {
+	return m.add("json body "+jm.String(), func(_ *http.Request, body []byte) string {
+		doc, err := decodeJSON(body)
+		if err != nil {
+			return fmt.Sprintf("body is not json: %s", err)
+		}
+		if jm.matchDoc(doc) {
+			return ""
+		}
+		return "json body does not match: " + jm.diff(doc)
+	})
+}
+
+// Matches implements gomock.Matcher.
+func (m *HTTPRequestMatcher) Matches(x interface{}) bool {
+	r, ok := x.(*http.Request)
+	if !ok {
+		return false
+	}
+	return len(m.mismatches(r)) == 0
+}
+
+func (m *HTTPRequestMatcher) mismatches(r *http.Request) []string {
+	body := requestBody(r)
+	var failed []string
+	for _, c := range m.checks {
+		if msg := c.check(r, body); msg != "" {
+			failed = append(failed, msg)
+		}
+	}
+	return failed
+}
+
+// String implements gomock.Matcher.
+func (m *HTTPRequestMatcher) String() string {
+	if len(m.checks) == 0 {
+		return "is any request"
+	}
+	descriptions := make([]string, 0, len(m.checks))
+	for _, c := range m.checks {
+		descriptions = append(descriptions, c.description)
+	}
+	return "is request with " + strings.Join(descriptions, ", ")
+}
+
+// Got implements gomock.GotFormatter, it prints the request and all mismatches.
+func (m *HTTPRequestMatcher) Got(got interface{}) string {
+	r, ok := got.(*http.Request)
+	if !ok {
+		return fmt.Sprintf("%v (%T is not *http.Request)", got, got)
+	}
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("%s %s\n", r.Method, r.URL.RequestURI()))
+	for _, msg := range m.mismatches(r) {
+		buf.WriteString(msg)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// requestBody reads the body and replaces it with a copy so the request can be read again.
+func requestBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body, _ := io.ReadAll(r.Body) //nolint:errcheck // partial body is matched as is
+	_ = r.Body.Close()            //nolint:errcheck
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body
+}
@@ -0,0 +1,89 @@
+package goat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPRequestMatcher(t *testing.T) {
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1/orders/42?dry_run=true", strings.NewReader(`{"sku":"a","qty":2}`))
+		r.Header.Set("Authorization", "Bearer token")
+		return r
+	}
+
+	tests := []struct {
+		matcher *HTTPRequestMatcher
+		name    string
+		want    bool
+	}{
+		{
+			name:    "any",
+			matcher: MatchRequest(),
+			want:    true,
+		},
+		{
+			name: "all checks",
+			matcher: MatchRequest().
+				Method(http.MethodPost).
+				Path("/v1/orders/*").
+				Header("Authorization", "Bearer token").
+				Query("dry_run", "true").
+				JSONBody(JSONSubset(`{"sku":"a"}`)),
+			want: true,
+		},
+		{
+			name:    "method",
+			matcher: MatchRequest().Method(http.MethodGet),
+			want:    false,
+		},
+		{
+			name:    "path",
+			matcher: MatchRequest().Path("/v1/users/*"),
+			want:    false,
+		},
+		{
+			name:    "header",
+			matcher: MatchRequest().Header("Authorization", "Bearer other"),
+			want:    false,
+		},
+		{
+			name:    "query",
+			matcher: MatchRequest().Query("dry_run", "false"),
+			want:    false,
+		},
+		{
+			name:    "body",
+			matcher: MatchRequest().JSONBody(JSONEq(`{"sku":"a"}`)),
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest()
+			require.Equal(t, tt.want, tt.matcher.Matches(r))
+
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"sku":"a","qty":2}`, string(body))
+		})
+	}
+
+	require.False(t, MatchRequest().Matches("not a request"))
+}
+
+func TestHTTPRequestMatcherGot(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/orders", strings.NewReader(`{"sku":"b"}`))
+	m := MatchRequest().Method(http.MethodPost).JSONBody(JSONEq(`{"sku":"a"}`))
+
+	got := m.Got(r)
+	require.Contains(t, got, "GET /v1/orders")
+	require.Contains(t, got, "method is GET, want POST")
+	require.Contains(t, got, "diff (-want +got)")
+	require.Contains(t, m.String(), "method POST")
+}
@@ -1,7 +1,10 @@
 package goat
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 )
 
 const (
@@ -16,8 +20,13 @@ const (
 )
 
 type HTTPMockHandler struct {
-	server   *http.ServeMux
-	listener net.Listener
+	server      *http.ServeMux
+	listener    net.Listener
+	faults      *HTTPFaults
+	journal     *httpJournal
+	clientCerts []*x509.Certificate
+	tls         bool
+	m           sync.Mutex
 }
 
 type responseLogger struct {
@@ -42,6 +51,22 @@ func (r *responseLogger) Write(b []byte) (int, error) {
 	return r.w.Write(b)
 }
 
+// Flush lets streaming mocks (SSE) work in debug mode.
+func (r *responseLogger) Flush() {
+	if f, ok := r.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket mocks work in debug mode.
+func (r *responseLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
 func (r *responseLogger) WriteHeader(statusCode int) {
 	_, _ = r.out.WriteString(fmt.Sprintf("status: %d\n", statusCode)) //nolint:errcheck
 
@@ -90,7 +115,9 @@ func loggerMiddleware(next http.Handler) http.Handler {
 
 func NewHTTPMockHandler(schema, address string, cb func(server *http.ServeMux)) (*HTTPMockHandler, error) {
 	h := &HTTPMockHandler{
-		server: http.NewServeMux(),
+		server:  http.NewServeMux(),
+		faults:  newHTTPFaults(),
+		journal: newHTTPJournal(),
 	}
 	cb(h.server)
 	l, err := net.Listen(schema, address)
@@ -101,12 +128,70 @@ func NewHTTPMockHandler(schema, address string, cb func(server *http.ServeMux))
 	return h, nil
 }
 
+// Addr returns the resolved listen address.
+func (h *HTTPMockHandler) Addr() string {
+	return h.listener.Addr().String()
+}
+
+// Faults returns fault injection settings of the server.
+func (h *HTTPMockHandler) Faults() *HTTPFaults {
+	return h.faults
+}
+
+// EnableTLS makes the server accept TLS connections only. Must be called before Start.
+func (h *HTTPMockHandler) EnableTLS(cfg *tls.Config) {
+	h.listener = tls.NewListener(h.listener, cfg)
+	h.tls = true
+}
+
+// ClientCertificates returns leaf certificates presented by clients, one per request made with a certificate.
+func (h *HTTPMockHandler) ClientCertificates() []*x509.Certificate {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return append([]*x509.Certificate(nil), h.clientCerts...)
+}
+
+func (h *HTTPMockHandler) clientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			h.m.Lock()
+			h.clientCerts = append(h.clientCerts, r.TLS.PeerCertificates[0])
+			h.m.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rebind replaces handlers of the server with ones registered by cb, connections are kept open
+func (h *HTTPMockHandler) rebind(cb func(server *http.ServeMux)) {
+	mux := http.NewServeMux()
+	cb(mux)
+	h.m.Lock()
+	h.server = mux
+	h.m.Unlock()
+}
+
+// serveMux dispatches requests to the current handlers of the server
+func (h *HTTPMockHandler) serveMux() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.m.Lock()
+		mux := h.server
+		h.m.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
 func (h *HTTPMockHandler) Start() error {
 	var handler http.Handler
 	if strings.ToLower(os.Getenv("GOAT_HTTP_DEBUG")) == "true" {
-		handler = loggerMiddleware(h.server)
+		handler = loggerMiddleware(h.serveMux())
 	} else {
-		handler = h.server
+		handler = h.serveMux()
+	}
+
+	handler = h.journal.middleware(h.faults.middleware(handler))
+	if h.tls {
+		handler = h.clientCertMiddleware(handler)
 	}
 
 	return http.Serve(h.listener, handler) //nolint:gosec 
@@ -0,0 +1,311 @@
+package goat
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	jsonObjectType = reflect.TypeOf(map[string]interface{}{})
+
+	// jsonDocs keeps numbers of documents exact: floats are not rounded and big integers are not turned into floats
+	jsonDocs = jsoniter.Config{
+		EscapeHTML:             true,
+		SortMapKeys:            true,
+		ValidateJsonRawMessage: true,
+		UseNumber:              true,
+	}.Froze()
+)
+
+type (
+	// JSONMatcher implements gomock.Matcher and gomock.GotFormatter for JSON documents.
+	// It accepts []byte, string and json.RawMessage documents, any other value is marshaled to JSON before comparison.
+	// io.Reader is not supported because gomock may call Matches several times.
+	// Numbers are compared exactly, predicates get them as float64.
+	JSONMatcher struct {
+		expected    interface{}
+		err         error
+		ignored     map[string]bool
+		predicates  []jsonPathPredicate
+		description []string
+		compare     bool
+		subset      bool
+	}
+
+	jsonPathPredicate struct {
+		pred func(v interface{}) bool
+		path string
+	}
+)
+
+// JSONEq returns a matcher checking that the document is equal to the expected one ignoring formatting and key order.
+// The expected value is a JSON string, []byte, json.RawMessage or any value marshaled to JSON.
+// If it is not valid JSON, the matcher matches nothing and reports the error.
+func JSONEq(expected interface{}) *JSONMatcher {
+	return newJSONMatcher(expected, false)
+}
+
+// JSONSubset returns a matcher checking that the document contains all fields of the expected one, extra fields are allowed.
+func JSONSubset(expected interface{}) *JSONMatcher {
+	return newJSONMatcher(expected, true)
+}
+
+// JSONPath returns a matcher checking only the value at the path with the predicate, e.g. "order.items.0.sku" or "$.order.items[0].sku".
+func JSONPath(path string, pred func(v interface{}) bool) *JSONMatcher {
+	m := &JSONMatcher{
+		ignored: make(map[string]bool),
+	}
+	return m.WithPath(path, pred)
+}
+
+func newJSONMatcher(expected interface{}, subset bool) *JSONMatcher {
+	doc, err := decodeJSON(expected)
+	if err != nil {
+		err = fmt.Errorf("invalid expected json: %w", err)
+	}
+	m := &JSONMatcher{
+		expected: doc,
+		err:      err,
+		ignored:  make(map[string]bool),
+		compare:  true,
+		subset:   subset,
+	}
+	if subset {
+		m.description = append(m.description, "subset")
+	}
+	return m
+}
+
+// IgnoreFields skips fields by dotted path, array indexes are transparent: "items.id" ignores id of every item.
+func (m *JSONMatcher) IgnoreFields(paths ...string) *JSONMatcher {
+	for _, p := range paths {
+		m.ignored[p] = true
+	}
+	m.description = append(m.description, "ignoring "+strings.Join(paths, ", "))
+	return m
+}
+
+// WithPath adds a predicate for the value at the path, the path is not compared with the expected document.
+func (m *JSONMatcher) WithPath(path string, pred func(v interface{}) bool) *JSONMatcher {
+	m.predicates = append(m.predicates, jsonPathPredicate{path: path, pred: pred})
+	m.ignored[jsonFieldPath(parseJSONPath(path))] = true
+	m.description = append(m.description, path+" matches predicate")
+	return m
+}
+
+func (m *JSONMatcher) options() cmp.Options {
+	opts := cmp.Options{cmpopts.EquateEmpty(), cmp.Comparer(equalJSONNumbers)}
+	if len(m.ignored) != 0 {
+		opts = append(opts, cmp.FilterPath(func(p cmp.Path) bool {
+			return m.ignored[jsonCmpPath(p)]
+		}, cmp.Ignore()))
+	}
+	if m.subset {
+		opts = append(opts, cmp.FilterPath(func(p cmp.Path) bool {
+			mi, ok := p.Last().(cmp.MapIndex)
+			if !ok || p.Index(-2).Type() != jsonObjectType {
+				return false
+			}
+			want, _ := mi.Values()
+			return !want.IsValid()
+		}, cmp.Ignore()))
+	}
+	return opts
+}
+
+// Matches implements gomock.Matcher.
+func (m *JSONMatcher) Matches(x interface{}) bool {
+	doc, err := decodeJSON(x)
+	if err != nil {
+		return false
+	}
+	return m.matchDoc(doc)
+}
+
+func (m *JSONMatcher) matchDoc(doc interface{}) bool {
+	if m.err != nil || len(m.failedPredicates(doc)) != 0 {
+		return false
+	}
+	return !m.compare || cmp.Equal(m.expected, doc, m.options())
+}
+
+func (m *JSONMatcher) failedPredicates(doc interface{}) []string {
+	var failed []string
+	for _, p := range m.predicates {
+		v, ok := LookupJSONPath(doc, p.path)
+		if !ok || !p.pred(floatJSONNumbers(v)) {
+			failed = append(failed, p.path)
+		}
+	}
+	return failed
+}
+
+// String implements gomock.Matcher.
+func (m *JSONMatcher) String() string {
+	var s string
+	switch {
+	case m.err != nil:
+		s = "is json (" + m.err.Error() + ")"
+	case m.compare:
+		data, _ := jsonDocs.Marshal(m.expected) //nolint:errcheck // decoded json is always marshalable
+		s = "is json " + string(data)
+	default:
+		s = "is json"
+	}
+	if len(m.description) != 0 {
+		s += " (" + strings.Join(m.description, "; ") + ")"
+	}
+	return s
+}
+
+// Got implements gomock.GotFormatter, it prints the structural diff between expected and actual documents.
+func (m *JSONMatcher) Got(got interface{}) string {
+	doc, err := decodeJSON(got)
+	if err != nil {
+		return fmt.Sprintf("%v (invalid json: %s)", got, err)
+	}
+	return m.diff(doc)
+}
+
+func (m *JSONMatcher) diff(doc interface{}) string {
+	var buf strings.Builder
+	data, _ := jsonDocs.Marshal(doc) //nolint:errcheck // decoded json is always marshalable
+	buf.WriteString(truncateBody(string(data)))
+	buf.WriteString("\n")
+	if m.err != nil {
+		buf.WriteString(m.err.Error())
+		buf.WriteString("\n")
+		return buf.String()
+	}
+	if m.compare {
+		if diff := cmp.Diff(m.expected, doc, m.options()); diff != "" {
+			buf.WriteString("diff (-want +got):\n")
+			buf.WriteString(diff)
+		}
+	}
+	for _, path := range m.failedPredicates(doc) {
+		buf.WriteString(fmt.Sprintf("value at %s does not match predicate\n", path))
+	}
+	return buf.String()
+}
+
+// LookupJSONPath returns the value of the decoded JSON document at the path.
+// The path is dotted with optional "$." prefix, array elements are addressed by index: "items.0.id" or "$.items[0].id".
+func LookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	cur := doc
+	for _, key := range parseJSONPath(path) {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func parseJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// jsonFieldPath joins object keys of the path skipping array indexes.
+func jsonFieldPath(keys []string) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, err := strconv.Atoi(key); err == nil {
+			continue
+		}
+		names = append(names, key)
+	}
+	return strings.Join(names, ".")
+}
+
+func jsonCmpPath(p cmp.Path) string {
+	var names []string
+	for _, step := range p {
+		if mi, ok := step.(cmp.MapIndex); ok {
+			names = append(names, mi.Key().String())
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+func decodeJSON(x interface{}) (interface{}, error) {
+	var data []byte
+	switch v := x.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case io.Reader:
+		return nil, fmt.Errorf("io.Reader is not supported")
+	default:
+		var err error
+		if data, err = jsonDocs.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc interface{}
+	if err := jsonDocs.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// equalJSONNumbers compares numbers by value, so 1 equals 1.0 and big integers are compared exactly
+func equalJSONNumbers(a, b stdjson.Number) bool {
+	x, okX := new(big.Rat).SetString(string(a))
+	y, okY := new(big.Rat).SetString(string(b))
+	if !okX || !okY {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+// floatJSONNumbers converts numbers of the decoded value to float64
+func floatJSONNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case stdjson.Number:
+		f, _ := v.Float64() //nolint:errcheck // decoded numbers are valid
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = floatJSONNumbers(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = floatJSONNumbers(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
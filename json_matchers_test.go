@@ -0,0 +1,116 @@
+package goat
+
+import (
+	stdjson "encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONMatchers(t *testing.T) {
+	got := `{"id":"ord_1","total":10.5,"items":[{"sku":"a","id":1},{"sku":"b","id":2}],"meta":{"source":"web"}}`
+
+	tests := []struct {
+		matcher *JSONMatcher
+		name    string
+		want    bool
+	}{
+		{
+			name:    "equal with other key order",
+			matcher: JSONEq(`{"meta":{"source":"web"},"items":[{"id":1,"sku":"a"},{"id":2,"sku":"b"}],"total":10.5,"id":"ord_1"}`),
+			want:    true,
+		},
+		{
+			name:    "different",
+			matcher: JSONEq(`{"id":"ord_2"}`),
+			want:    false,
+		},
+		{
+			name:    "subset",
+			matcher: JSONSubset(map[string]interface{}{"id": "ord_1", "meta": map[string]string{}}),
+			want:    true,
+		},
+		{
+			name:    "subset mismatch",
+			matcher: JSONSubset(`{"total":11}`),
+			want:    false,
+		},
+		{
+			name: "ignore fields",
+			matcher: JSONEq(`{"id":"ord_9","total":10.5,"items":[{"sku":"a","id":7},{"sku":"b","id":8}],"meta":{"source":"web"}}`).
+				IgnoreFields("id", "items.id"),
+			want: true,
+		},
+		{
+			name: "path",
+			matcher: JSONPath("$.items[1].sku", func(v interface{}) bool {
+				return v == "b"
+			}),
+			want: true,
+		},
+		{
+			name: "path mismatch",
+			matcher: JSONPath("items.5.sku", func(interface{}) bool {
+				return true
+			}),
+			want: false,
+		},
+		{
+			name: "subset with path",
+			matcher: JSONSubset(`{"id":"any"}`).WithPath("id", func(v interface{}) bool {
+				return strings.HasPrefix(v.(string), "ord_")
+			}),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.matcher.Matches(got))
+			require.Equal(t, tt.want, tt.matcher.Matches([]byte(got)))
+		})
+	}
+
+	require.False(t, JSONEq(`{}`).Matches("not json"))
+}
+
+func TestJSONMatcherNumbers(t *testing.T) {
+	// int64 IDs above 2^53 differ
+	require.False(t, JSONEq(`{"id":9007199254740993}`).Matches(`{"id":9007199254740992}`))
+	require.True(t, JSONEq(map[string]int64{"id": 9007199254740993}).Matches(`{"id":9007199254740993}`))
+	// floats are not rounded
+	require.False(t, JSONEq(map[string]float64{"total": 0.1234567891}).Matches(`{"total":0.123457}`))
+	require.True(t, JSONSubset(`{"total":10}`).Matches(`{"total":10.0,"id":1}`))
+	require.True(t, JSONPath("total", func(v interface{}) bool { return v == 10.5 }).Matches(`{"total":10.5}`))
+}
+
+func TestJSONMatcherInvalidExpected(t *testing.T) {
+	m := JSONEq(`{"id":`)
+	require.False(t, m.Matches(`{"id":1}`))
+	require.Contains(t, m.String(), "invalid expected json")
+	require.Contains(t, m.Got(`{"id":1}`), "invalid expected json")
+}
+
+func TestJSONMatcherGot(t *testing.T) {
+	m := JSONEq(`{"id":"ord_1","total":10}`)
+	got := m.Got(`{"id":"ord_1","total":12}`)
+	require.Contains(t, got, "diff (-want +got)")
+	require.Contains(t, got, "total")
+	require.Contains(t, m.String(), `"total":10`)
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	doc, err := decodeJSON(`{"a":{"b":[{"c":1}]}}`)
+	require.NoError(t, err)
+
+	v, ok := LookupJSONPath(doc, "a.b.0.c")
+	require.True(t, ok)
+	require.Equal(t, stdjson.Number("1"), v)
+
+	_, ok = LookupJSONPath(doc, "$.a.b[1].c")
+	require.False(t, ok)
+
+	v, ok = LookupJSONPath(doc, "$")
+	require.True(t, ok)
+	require.Equal(t, doc, v)
+}
@@ -0,0 +1,329 @@
+package goat
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const logFieldStatsExtension = ".logfields.json"
+
+var updateLogFields = flag.Bool("goat.update-log-fields", false, "rewrite GOAT_LOG_FIELDS_FILE with the fields seen in logs")
+
+type (
+	// LogFieldsUpdate lists changes made by UpdateLogFieldsFile
+	LogFieldsUpdate struct {
+		Added   []string
+		Removed []string
+		Changed []string
+	}
+
+	// LogFieldStats is collected from logs of the app
+	LogFieldStats struct {
+		Fields map[string]string `json:"fields"`
+		// File is the fields file of the executor, it is used when GOAT_LOG_FIELDS_FILE is not set
+		File            string         `json:"file,omitempty"`
+		Violations      []LogViolation `json:"violations,omitempty"`
+		UnmarshalErrors int            `json:"unmarshal_errors"`
+	}
+
+	// LogFieldsReporter is implemented by executors collecting log fields, Flow.Stop merges their stats into Env.
+	// Custom BaseExecutor implementations can write stdout to NewLogFieldsWriter and return its stats.
+	LogFieldsReporter interface {
+		LogFieldStats() LogFieldStats
+	}
+
+	// LogFieldsWriter collects log fields of lines written to it
+	LogFieldsWriter interface {
+		io.Writer
+		LogFieldsReporter
+	}
+)
+
+// NewLogFieldsWriter returns a collector of log fields for custom executors, lines are validated
+// with the schema if GOAT_LOG_FIELDS_FILE is a JSON schema
+func NewLogFieldsWriter(opts ...LogFieldsOption) LogFieldsWriter {
+	return newFileFieldsCollector(getFieldsCollectorFilePath(), opts...)
+}
+
+// Empty reports whether the file was already up to date
+func (u LogFieldsUpdate) Empty() bool {
+	return len(u.Added) == 0 && len(u.Removed) == 0 && len(u.Changed) == 0
+}
+
+func shouldUpdateLogFields() bool {
+	if *updateLogFields {
+		return true
+	}
+	update, _ := strconv.ParseBool(os.Getenv("GOAT_UPDATE_LOG_FIELDS")) //nolint:errcheck // invalid value is false
+	return update
+}
+
+// checkLogFields validates collected log fields against GOAT_LOG_FIELDS_FILE.
+// With GOAT_LOG_FIELDS_DIR the stats are only written to the directory, to validate fields of all packages together.
+func (e *Env) checkLogFields() error {
+	e.logStatsMu.Lock()
+	defer e.logStatsMu.Unlock()
+	fieldsPath := getFieldsCollectorFilePath()
+	if fieldsPath == "" {
+		fieldsPath = e.logStats.File
+	}
+	if dir := getFieldsCollectorDir(); dir != "" && fieldsPath != "" {
+		return writeLogFieldStats(filepath.Join(dir, logFieldStatsName()+logFieldStatsExtension), e.logStats)
+	}
+	return checkLogFieldStats(fieldsPath, e.logStats)
+}
+
+// ValidateLogFieldsDir merges stats written by test packages with GOAT_LOG_FIELDS_DIR and validates them
+// against the fields file, like a single test binary does. GOAT_UPDATE_LOG_FIELDS rewrites the CSV file.
+func ValidateLogFieldsDir(dir, fieldsPath string) error {
+	stats, err := MergeLogFieldsDir(dir)
+	if err != nil {
+		return err
+	}
+	return checkLogFieldStats(fieldsPath, stats)
+}
+
+// checkLogFieldStats validates stats against the CSV file or the JSON schema,
+// the CSV file is rewritten instead with -goat.update-log-fields flag or GOAT_UPDATE_LOG_FIELDS=true
+func checkLogFieldStats(logFieldsPath string, stats LogFieldStats) error {
+	switch {
+	case logFieldsPath == "":
+		return nil
+	case isLogSchemaFile(logFieldsPath):
+		if shouldUpdateLogFields() {
+			fmt.Println("log schema can't be updated automatically, validating it")
+		}
+		return validateLogSchema(logFieldsPath, stats.Violations, stats.UnmarshalErrors)
+	case shouldUpdateLogFields():
+		update, err := UpdateLogFieldsFile(logFieldsPath, stats.Fields)
+		if err != nil {
+			return err
+		}
+		printLogFieldsUpdate(logFieldsPath, update)
+		if stats.UnmarshalErrors != 0 {
+			return fmt.Errorf("found json unmarshal errors: %d", stats.UnmarshalErrors)
+		}
+		return nil
+	default:
+		return validateLogFields(logFieldsPath, stats.Fields, stats.UnmarshalErrors)
+	}
+}
+
+// Merge adds fields, violations and errors of other stats.
+// A field with different types gets all of them joined by "|", so validation reports the conflict.
+func (s *LogFieldStats) Merge(other LogFieldStats) {
+	if s.Fields == nil {
+		s.Fields = make(map[string]string, len(other.Fields))
+	}
+	for name, fieldType := range other.Fields {
+		s.Fields[name] = mergeFieldTypes(s.Fields[name], fieldType)
+	}
+	if s.File == "" {
+		s.File = other.File
+	}
+	s.Violations = append(s.Violations, other.Violations...)
+	s.UnmarshalErrors += other.UnmarshalErrors
+}
+
+func mergeFieldTypes(a, b string) string {
+	if a == "" || a == b {
+		return b
+	}
+	types := strings.Split(a, "|")
+	for _, t := range types {
+		if t == b {
+			return a
+		}
+	}
+	types = append(types, b)
+	sort.Strings(types)
+	return strings.Join(types, "|")
+}
+
+// writeLogFieldStats writes stats of the test binary, the file of the previous run is overwritten
+func writeLogFieldStats(path string, stats LogFieldStats) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("log field stats are written to", path)
+	return nil
+}
+
+// logFieldStatsName returns the name of the stats file of the test binary, it is the same in every run,
+// e.g. github.com_org_app_api.test for the package github.com/org/app/api
+func logFieldStatsName() string {
+	name := filepath.Base(os.Args[0])
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Path != "" {
+		name = bi.Path
+	}
+	return artifactName(strings.ReplaceAll(name, "/", "_"))
+}
+
+// MergeLogFieldsDir merges stats written by test packages with GOAT_LOG_FIELDS_DIR.
+// Every package overwrites its own file, packages removed or renamed since the last run leave stale files.
+func MergeLogFieldsDir(dir string) (LogFieldStats, error) {
+	var merged LogFieldStats
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+logFieldStatsExtension))
+	if err != nil {
+		return merged, err
+	}
+	if len(paths) == 0 {
+		return merged, fmt.Errorf("no log field stats in %s", dir)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return merged, err
+		}
+		var stats LogFieldStats
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return merged, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		merged.Merge(stats)
+	}
+	return merged, nil
+}
+
+// CollectLogFields reads log lines and returns types of their fields, lines failing to parse are counted
+func CollectLogFields(r io.Reader, opts ...LogFieldsOption) (fields map[string]string, unmarshalErrors int, err error) {
+	fc := newFieldsCollector(opts...)
+	if _, err := io.Copy(fc, r); err != nil {
+		return nil, 0, err
+	}
+	// the last line may have no line break
+	if fc.buf.Len() != 0 {
+		if _, err := fc.Write([]byte("\n")); err != nil {
+			return nil, 0, err
+		}
+	}
+	return fc.fields, fc.unmarshalErrors, nil
+}
+
+// UpdateLogFieldsFile rewrites the CSV fields file: types of known fields are updated, vanished fields are removed
+// and new fields are appended in name order. Descriptions, other columns and the order of rows are preserved.
+// The file is created if it does not exist.
+func UpdateLogFieldsFile(path string, fields map[string]string) (LogFieldsUpdate, error) {
+	var update LogFieldsUpdate
+
+	records, err := readLogFieldsFile(path)
+	if err != nil {
+		return update, err
+	}
+	fieldNameIndex, typeIndex, err := processHeaders(records[0])
+	if err != nil {
+		return update, err
+	}
+
+	result := [][]string{records[0]}
+	seen := make(map[string]bool, len(records))
+	for _, record := range records[1:] {
+		name := record[fieldNameIndex]
+		fieldType, ok := fields[name]
+		if !ok {
+			update.Removed = append(update.Removed, name)
+			continue
+		}
+		seen[name] = true
+		if record[typeIndex] != fieldType {
+			update.Changed = append(update.Changed, name)
+			record[typeIndex] = fieldType
+		}
+		result = append(result, record)
+	}
+
+	for name := range fields {
+		if !seen[name] {
+			update.Added = append(update.Added, name)
+		}
+	}
+	sort.Strings(update.Added)
+	for _, name := range update.Added {
+		record := make([]string, len(records[0]))
+		record[fieldNameIndex] = name
+		record[typeIndex] = fields[name]
+		result = append(result, record)
+	}
+
+	if update.Empty() {
+		return update, nil
+	}
+	return update, writeLogFieldsFile(path, result)
+}
+
+// readLogFieldsFile returns trimmed records of the file, the first one is the header
+func readLogFieldsFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return [][]string{{FieldNameHeader, TypeHeader, DescriptionHeader}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	csvReader := csv.NewReader(f)
+	csvReader.FieldsPerRecord = -1
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return [][]string{{FieldNameHeader, TypeHeader, DescriptionHeader}}, nil
+	}
+	for i, record := range records {
+		records[i] = trimSpacesInRecords(record)
+		// short rows are padded to keep indexes of the header valid
+		for len(records[i]) < len(records[0]) {
+			records[i] = append(records[i], "")
+		}
+	}
+	return records, nil
+}
+
+func writeLogFieldsFile(path string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(records); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func printLogFieldsUpdate(path string, update LogFieldsUpdate) {
+	if update.Empty() {
+		fmt.Println("log fields file is up to date", path)
+		return
+	}
+	fmt.Println("=================================================")
+	fmt.Println("Log fields file is updated", path)
+	for _, name := range update.Added {
+		fmt.Println("added", name)
+	}
+	for _, name := range update.Removed {
+		fmt.Println("removed", name)
+	}
+	for _, name := range update.Changed {
+		fmt.Println("changed type of", name)
+	}
+	fmt.Println("=================================================")
+}
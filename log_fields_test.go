@@ -0,0 +1,121 @@
+package goat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectLogFields(t *testing.T) {
+	fields, unmarshalErrors, err := CollectLogFields(strings.NewReader(
+		"{\"level\":\"info\",\"n\":1,\"error\":null}\nstarting app\n{\"level\":\"error\",\"error\":\"boom\"}"))
+	require.NoError(t, err)
+	require.Equal(t, 1, unmarshalErrors)
+	require.Equal(t, map[string]string{"level": "string", "n": "float64", "error": "string"}, fields)
+}
+
+func TestUpdateLogFieldsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fields.csv")
+	require.NoError(t, os.WriteFile(path, []byte(`field name, type, description, owner
+msg,string,"message, human readable",core
+n,string,counter
+old,bool,removed field,core
+`), 0o600))
+
+	update, err := UpdateLogFieldsFile(path, map[string]string{
+		"msg":   "string",
+		"n":     "float64",
+		"zeta":  "bool",
+		"alpha": "string",
+	})
+	require.NoError(t, err)
+	require.Equal(t, LogFieldsUpdate{
+		Added:   []string{"alpha", "zeta"},
+		Removed: []string{"old"},
+		Changed: []string{"n"},
+	}, update)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `field name,type,description,owner
+msg,string,"message, human readable",core
+n,float64,counter,
+alpha,string,,
+zeta,bool,,
+`, string(data))
+
+	update, err = UpdateLogFieldsFile(path, map[string]string{"msg": "string", "n": "float64", "zeta": "bool", "alpha": "string"})
+	require.NoError(t, err)
+	require.True(t, update.Empty())
+}
+
+func TestUpdateLogFieldsFileCreates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fields.csv")
+	_, err := UpdateLogFieldsFile(path, map[string]string{"level": "string"})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "field name,type,description\nlevel,string,\n", string(data))
+	require.NoError(t, validateLogFields(path, map[string]string{"level": "string"}, 0))
+}
+
+func TestCheckLogFieldsUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fields.csv")
+	t.Setenv("GOAT_LOG_FIELDS_FILE", path)
+	env := &Env{}
+	env.mergeLogFieldStats(LogFieldStats{Fields: map[string]string{"level": "string"}})
+
+	require.Error(t, env.checkLogFields())
+
+	t.Setenv("GOAT_UPDATE_LOG_FIELDS", "true")
+	require.NoError(t, env.checkLogFields())
+	require.FileExists(t, path)
+
+	t.Setenv("GOAT_UPDATE_LOG_FIELDS", "")
+	require.NoError(t, env.checkLogFields())
+}
+
+func TestLogFieldsDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "stats")
+	path := filepath.Join(t.TempDir(), "fields.csv")
+	require.NoError(t, os.WriteFile(path, []byte("field name,type,description\nlevel,string,\nstatus,float64,\nuser,string,\n"), 0o600))
+	t.Setenv("GOAT_LOG_FIELDS_FILE", path)
+	t.Setenv("GOAT_LOG_FIELDS_DIR", dir)
+
+	// every package sees only a part of the fields
+	require.NoError(t, writeLogFieldStats(filepath.Join(dir, "api"+logFieldStatsExtension),
+		LogFieldStats{Fields: map[string]string{"level": "string", "status": "float64"}}))
+	require.NoError(t, writeLogFieldStats(filepath.Join(dir, "worker"+logFieldStatsExtension),
+		LogFieldStats{Fields: map[string]string{"level": "string", "user": "string"}}))
+	require.NoError(t, ValidateLogFieldsDir(dir, path))
+
+	// the next run of the package overwrites its stats
+	broken := &Env{}
+	broken.mergeLogFieldStats(LogFieldStats{Fields: map[string]string{"status": "string"}, UnmarshalErrors: 1})
+	require.NoError(t, broken.checkLogFields())
+	stats, err := MergeLogFieldsDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"level": "string", "status": "float64|string", "user": "string"}, stats.Fields)
+	require.Equal(t, 1, stats.UnmarshalErrors)
+	require.Error(t, ValidateLogFieldsDir(dir, path))
+
+	fixed := &Env{}
+	fixed.mergeLogFieldStats(LogFieldStats{Fields: map[string]string{"status": "float64"}})
+	require.NoError(t, fixed.checkLogFields())
+	require.FileExists(t, filepath.Join(dir, "github.com_Educentr_goat.test"+logFieldStatsExtension))
+	require.NoError(t, ValidateLogFieldsDir(dir, path))
+
+	_, err = MergeLogFieldsDir(t.TempDir())
+	require.ErrorContains(t, err, "no log field stats")
+}
+
+func TestLogFieldsWriter(t *testing.T) {
+	w := NewLogFieldsWriter()
+	_, err := w.Write([]byte("{\"level\":\"info\"}\n"))
+	require.NoError(t, err)
+	require.Equal(t, LogFieldStats{Fields: map[string]string{"level": "string"}}, w.LogFieldStats())
+}
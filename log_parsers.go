@@ -0,0 +1,184 @@
+package goat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	LogParserJSON   = "json"
+	LogParserLogfmt = "logfmt"
+	LogParserKV     = "kv"
+)
+
+type (
+	// LogLineParser decodes one log line into fields, lines failing to parse are counted as errors
+	LogLineParser interface {
+		Parse(line []byte) (map[string]interface{}, error)
+	}
+
+	// LogLineParserFunc is a function implementing LogLineParser
+	LogLineParserFunc func(line []byte) (map[string]interface{}, error)
+
+	// LogFieldsOption configures collection of log fields
+	LogFieldsOption func(fc *fieldsCollector)
+)
+
+// Parse implements LogLineParser
+func (f LogLineParserFunc) Parse(line []byte) (map[string]interface{}, error) {
+	return f(line)
+}
+
+// WithLogLineParser sets the parser of log lines, JSON by default or the one of GOAT_LOG_FORMAT
+func WithLogLineParser(parser LogLineParser) LogFieldsOption {
+	return func(fc *fieldsCollector) {
+		fc.parser = parser
+	}
+}
+
+// WithIgnoredLogPrefixes skips lines starting with any of the prefixes, e.g. banners of the app
+func WithIgnoredLogPrefixes(prefixes ...string) LogFieldsOption {
+	return func(fc *fieldsCollector) {
+		fc.ignorePrefixes = append(fc.ignorePrefixes, prefixes...)
+	}
+}
+
+// WithIgnoredLogPatterns skips lines matching any of the patterns
+func WithIgnoredLogPatterns(patterns ...*regexp.Regexp) LogFieldsOption {
+	return func(fc *fieldsCollector) {
+		fc.ignorePatterns = append(fc.ignorePatterns, patterns...)
+	}
+}
+
+// JSONLogParser parses JSON objects, one per line
+func JSONLogParser() LogLineParser {
+	return LogLineParserFunc(func(line []byte) (map[string]interface{}, error) {
+		var m map[string]interface{}
+		if err := json.Unmarshal(line, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	})
+}
+
+// LogfmtParser parses logfmt lines: level=info msg="request done" status=200.
+// Values are strings, keys without a value are errors to catch lines that are not logs.
+func LogfmtParser() LogLineParser {
+	return LogLineParserFunc(parseLogfmt)
+}
+
+// KeyValueParser parses lines of pairs separated by pairSep with keys and values separated by kvSep,
+// e.g. KeyValueParser(";", ":") for "level:info; msg:done". Keys and values are trimmed.
+func KeyValueParser(pairSep, kvSep string) LogLineParser {
+	return LogLineParserFunc(func(line []byte) (map[string]interface{}, error) {
+		m := make(map[string]interface{})
+		for _, pair := range strings.Split(strings.TrimSpace(string(line)), pairSep) {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, kvSep)
+			if !ok || strings.TrimSpace(key) == "" {
+				return nil, fmt.Errorf("pair %q has no key", pair)
+			}
+			m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		if len(m) == 0 {
+			return nil, errors.New("no fields in line")
+		}
+		return m, nil
+	})
+}
+
+// RegexpLogParser parses lines with named groups of the pattern, groups not taking part in the match are skipped:
+//
+//	RegexpLogParser(regexp.MustCompile(`^(?P<ts>\S+) (?P<level>[A-Z]+) (?P<msg>.*)$`))
+func RegexpLogParser(re *regexp.Regexp) LogLineParser {
+	return LogLineParserFunc(func(line []byte) (map[string]interface{}, error) {
+		line = bytes.TrimRight(line, "\r\n")
+		match := re.FindSubmatchIndex(line)
+		if match == nil {
+			return nil, fmt.Errorf("line does not match %s", re)
+		}
+		m := make(map[string]interface{})
+		for i, name := range re.SubexpNames() {
+			if name == "" || match[2*i] < 0 {
+				continue
+			}
+			m[name] = string(line[match[2*i]:match[2*i+1]])
+		}
+		return m, nil
+	})
+}
+
+// LogParserByName returns the parser of GOAT_LOG_FORMAT values: json, logfmt or kv (space separated key=value)
+func LogParserByName(name string) (LogLineParser, error) {
+	switch strings.ToLower(name) {
+	case "", LogParserJSON:
+		return JSONLogParser(), nil
+	case LogParserLogfmt:
+		return LogfmtParser(), nil
+	case LogParserKV:
+		return KeyValueParser(" ", "="), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", name)
+	}
+}
+
+// envLogParser returns the parser of GOAT_LOG_FORMAT, JSON if it is not set or invalid
+func envLogParser() LogLineParser {
+	parser, err := LogParserByName(os.Getenv("GOAT_LOG_FORMAT"))
+	if err != nil {
+		fmt.Println("GOAT_LOG_FORMAT:", err, "using json")
+		return JSONLogParser()
+	}
+	return parser
+}
+
+func parseLogfmt(line []byte) (map[string]interface{}, error) {
+	s := strings.TrimSpace(string(line))
+	m := make(map[string]interface{})
+	for s != "" {
+		end := strings.IndexAny(s, "= ")
+		if end <= 0 || s[end] != '=' {
+			return nil, fmt.Errorf("key without value in %q", s)
+		}
+		key := s[:end]
+		s = s[end+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			quoted, rest, err := cutQuoted(s)
+			if err != nil {
+				return nil, fmt.Errorf("value of %s: %w", key, err)
+			}
+			value, s = quoted, rest
+		} else {
+			value, s, _ = strings.Cut(s, " ")
+		}
+		m[key] = value
+		s = strings.TrimLeft(s, " ")
+	}
+	if len(m) == 0 {
+		return nil, errors.New("no fields in line")
+	}
+	return m, nil
+}
+
+// cutQuoted unquotes the leading quoted string of s and returns the rest
+func cutQuoted(s string) (value, rest string, err error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err = strconv.Unquote(s[:i+1])
+			return value, s[i+1:], err
+		}
+	}
+	return "", "", errors.New("unterminated quoted value")
+}
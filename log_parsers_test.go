@@ -0,0 +1,93 @@
+package goat
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogParsers(t *testing.T) {
+	tests := []struct {
+		parser  LogLineParser
+		want    map[string]interface{}
+		name    string
+		line    string
+		wantErr string
+	}{
+		{
+			name:   "logfmt",
+			parser: LogfmtParser(),
+			line:   `level=info msg="request \"done\"" status=200 empty= path=/v1`,
+			want:   map[string]interface{}{"level": "info", "msg": `request "done"`, "status": "200", "empty": "", "path": "/v1"},
+		},
+		{
+			name:    "logfmt banner",
+			parser:  LogfmtParser(),
+			line:    "Starting server on port=8080",
+			wantErr: "key without value",
+		},
+		{
+			name:    "logfmt unterminated",
+			parser:  LogfmtParser(),
+			line:    `msg="oops`,
+			wantErr: "unterminated quoted value",
+		},
+		{
+			name:   "kv",
+			parser: KeyValueParser(";", ":"),
+			line:   "level: info; msg: done;\n",
+			want:   map[string]interface{}{"level": "info", "msg": "done"},
+		},
+		{
+			name:    "kv without separator",
+			parser:  KeyValueParser(" ", "="),
+			line:    "level=info done",
+			wantErr: `pair "done" has no key`,
+		},
+		{
+			name:   "regexp",
+			parser: RegexpLogParser(regexp.MustCompile(`^(?P<level>[A-Z]+) (?P<msg>[^|]*)(?:\|(?P<trace>\w+))?$`)),
+			line:   "INFO started\n",
+			want:   map[string]interface{}{"level": "INFO", "msg": "started"},
+		},
+		{
+			name:    "regexp mismatch",
+			parser:  RegexpLogParser(regexp.MustCompile(`^(?P<level>[A-Z]+) `)),
+			line:    "started",
+			wantErr: "line does not match",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parser.Parse([]byte(tt.line))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := LogParserByName("xml")
+	require.ErrorContains(t, err, `unknown log format "xml"`)
+}
+
+func TestCollectLogFieldsIgnoredLines(t *testing.T) {
+	log := "=== banner ===\n\nlevel=info msg=started\nDEBUG 12:00 connected\nlevel=warn\nnot a log line\n"
+	fields, parseErrors, err := CollectLogFields(strings.NewReader(log),
+		WithLogLineParser(LogfmtParser()),
+		WithIgnoredLogPrefixes("==="),
+		WithIgnoredLogPatterns(regexp.MustCompile(`^DEBUG \d`)),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, parseErrors)
+	require.Equal(t, map[string]string{"level": "string", "msg": "string"}, fields)
+
+	t.Setenv("GOAT_LOG_FORMAT", "logfmt")
+	_, parseErrors, err = CollectLogFields(strings.NewReader("level=info\n"))
+	require.NoError(t, err)
+	require.Zero(t, parseErrors)
+}
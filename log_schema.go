@@ -0,0 +1,390 @@
+package goat
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	LogTypeString  = "string"
+	LogTypeNumber  = "number"
+	LogTypeInteger = "integer"
+	LogTypeBoolean = "boolean"
+	LogTypeObject  = "object"
+	LogTypeArray   = "array"
+	LogTypeAny     = "any"
+
+	LogFormatRFC3339 = "rfc3339"
+	LogFormatUUID    = "uuid"
+
+	logSchemaExtension = ".json"
+	// printed violations are limited, a broken field usually breaks every line
+	maxPrintedViolations = 50
+	maxViolationTextLen  = 200
+)
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type (
+	// LogSchema describes JSON log lines of the app. It is used instead of the CSV fields file
+	// when GOAT_LOG_FIELDS_FILE has .json extension:
+	//
+	//	{
+	//	  "fields": {
+	//	    "level":      {"type": "string", "enum": ["debug", "info", "warn", "error"], "required": true},
+	//	    "ts":         {"type": "string", "format": "rfc3339", "required": true},
+	//	    "request.id": {"type": "string", "format": "uuid", "nullable": true},
+	//	    "error":      {"type": "string"}
+	//	  },
+	//	  "rules": [{"when": {"level": "error"}, "require": ["error"]}],
+	//	  "logger_field": "logger",
+	//	  "loggers": {"http": {"fields": {"status": {"type": "integer", "required": true}}}}
+	//	}
+	LogSchema struct {
+		// Fields are keyed by dotted paths of nested objects
+		Fields map[string]*LogFieldSchema `json:"fields"`
+		// Loggers extend the schema for lines with the logger name in LoggerField
+		Loggers     map[string]*LogSchema `json:"loggers"`
+		LoggerField string                `json:"logger_field"`
+		Rules       []LogRule             `json:"rules"`
+		// AllowUnknown disables violations for fields missing in the schema
+		AllowUnknown bool `json:"allow_unknown"`
+	}
+
+	// LogFieldSchema describes one log field
+	LogFieldSchema struct {
+		// Type is one of string, number, integer, boolean, object, array or any, empty means any
+		Type string `json:"type"`
+		// Format is rfc3339 or uuid, it applies to strings
+		Format      string        `json:"format"`
+		Description string        `json:"description"`
+		Enum        []interface{} `json:"enum"`
+		Required    bool          `json:"required"`
+		Nullable    bool          `json:"nullable"`
+	}
+
+	// LogRule requires fields in lines matching all When values, e.g. every error line has the error field
+	LogRule struct {
+		When    map[string]interface{} `json:"when"`
+		Require []string               `json:"require"`
+	}
+
+	// LogViolation is a log line not matching the schema
+	LogViolation struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+		Text    string `json:"text"`
+		Line    int    `json:"line"`
+	}
+)
+
+func isLogSchemaFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), logSchemaExtension)
+}
+
+// LoadLogSchema reads and checks a JSON log schema
+func LoadLogSchema(path string) (*LogSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLogSchema(data)
+}
+
+// ParseLogSchema parses and checks a JSON log schema
+func ParseLogSchema(data []byte) (*LogSchema, error) {
+	var s LogSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse log schema: %w", err)
+	}
+	if err := s.check(""); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *LogSchema) check(logger string) error {
+	for path, field := range s.Fields {
+		if field == nil {
+			return fmt.Errorf("log schema %sfield %s: empty definition", loggerPrefix(logger), path)
+		}
+		switch field.Type {
+		case "", LogTypeString, LogTypeNumber, LogTypeInteger, LogTypeBoolean, LogTypeObject, LogTypeArray, LogTypeAny:
+		default:
+			return fmt.Errorf("log schema %sfield %s: unknown type %q", loggerPrefix(logger), path, field.Type)
+		}
+		switch field.Format {
+		case "", LogFormatRFC3339, LogFormatUUID:
+		default:
+			return fmt.Errorf("log schema %sfield %s: unknown format %q", loggerPrefix(logger), path, field.Format)
+		}
+	}
+	for name, ls := range s.Loggers {
+		if logger != "" {
+			return fmt.Errorf("log schema logger %s: loggers can't be nested", logger)
+		}
+		if ls == nil {
+			return fmt.Errorf("log schema logger %s: empty definition", name)
+		}
+		if err := ls.check(name); err != nil {
+			return err
+		}
+	}
+	if len(s.Loggers) != 0 && s.LoggerField == "" {
+		return fmt.Errorf("log schema: logger_field is required with loggers")
+	}
+	return nil
+}
+
+func loggerPrefix(logger string) string {
+	if logger == "" {
+		return ""
+	}
+	return "logger " + logger + " "
+}
+
+// Validate returns violations of one decoded log line, the Line and Text of violations are left empty
+func (s *LogSchema) Validate(line map[string]interface{}) []LogViolation {
+	schemas := []*LogSchema{s}
+	if s.LoggerField != "" {
+		if name, ok := lookupLogField(line, s.LoggerField); ok {
+			if ls, ok := s.Loggers[fmt.Sprint(name)]; ok {
+				// logger fields take precedence over the common ones
+				schemas = []*LogSchema{ls, s}
+			}
+		}
+	}
+	v := &logValidator{schemas: schemas}
+	for _, sc := range schemas {
+		v.allowUnknown = v.allowUnknown || sc.AllowUnknown
+	}
+
+	v.walk("", line)
+	for _, sc := range schemas {
+		v.checkRequired(sc, line)
+	}
+	sort.SliceStable(v.violations, func(i, j int) bool { return v.violations[i].Field < v.violations[j].Field })
+	return v.violations
+}
+
+type logValidator struct {
+	schemas      []*LogSchema
+	violations   []LogViolation
+	allowUnknown bool
+}
+
+func (v *logValidator) field(path string) *LogFieldSchema {
+	for _, s := range v.schemas {
+		if f, ok := s.Fields[path]; ok {
+			return f
+		}
+	}
+	return nil
+}
+
+func (v *logValidator) add(field, format string, args ...interface{}) {
+	v.violations = append(v.violations, LogViolation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// walk checks fields of the object, nested objects without a definition are checked field by field
+func (v *logValidator) walk(prefix string, obj map[string]interface{}) {
+	for key, value := range obj {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if f := v.field(path); f != nil {
+			v.checkValue(path, f, value)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) != 0 {
+			v.walk(path, nested)
+			continue
+		}
+		if !v.allowUnknown {
+			v.add(path, "unknown field of type %s", logValueType(value))
+		}
+	}
+}
+
+func (v *logValidator) checkValue(path string, f *LogFieldSchema, value interface{}) {
+	if value == nil {
+		if !f.Nullable {
+			v.add(path, "is null")
+		}
+		return
+	}
+	if actual := logValueType(value); !logTypeMatches(f.Type, actual, value) {
+		v.add(path, "type is %s, expected %s", actual, f.Type)
+		return
+	}
+	if len(f.Enum) != 0 && !logEnumContains(f.Enum, value) {
+		v.add(path, "value %v is not one of %v", value, f.Enum)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return
+	}
+	switch f.Format {
+	case LogFormatRFC3339:
+		if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+			v.add(path, "value %q is not an RFC3339 timestamp", str)
+		}
+	case LogFormatUUID:
+		if !uuidRe.MatchString(str) {
+			v.add(path, "value %q is not a UUID", str)
+		}
+	}
+}
+
+func (v *logValidator) checkRequired(s *LogSchema, line map[string]interface{}) {
+	for path, f := range s.Fields {
+		if !f.Required {
+			continue
+		}
+		if _, ok := lookupLogField(line, path); !ok {
+			v.add(path, "required field is missing")
+		}
+	}
+	for _, rule := range s.Rules {
+		if !rule.matches(line) {
+			continue
+		}
+		for _, path := range rule.Require {
+			if _, ok := lookupLogField(line, path); !ok {
+				v.add(path, "required field is missing when %s", rule.describe())
+			}
+		}
+	}
+}
+
+func (r LogRule) matches(line map[string]interface{}) bool {
+	for path, expected := range r.When {
+		value, ok := lookupLogField(line, path)
+		if !ok || !reflect.DeepEqual(value, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r LogRule) describe() string {
+	conditions := make([]string, 0, len(r.When))
+	for path, value := range r.When {
+		conditions = append(conditions, fmt.Sprintf("%s=%v", path, value))
+	}
+	sort.Strings(conditions)
+	return strings.Join(conditions, ",")
+}
+
+// lookupLogField finds a field by dotted path, keys with dots are supported too, e.g. "http.method": "GET"
+func lookupLogField(obj map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := obj[path]; ok {
+		return value, true
+	}
+	for i := strings.IndexByte(path, '.'); i != -1; i = nextDot(path, i) {
+		nested, ok := obj[path[:i]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := lookupLogField(nested, path[i+1:]); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func nextDot(path string, i int) int {
+	next := strings.IndexByte(path[i+1:], '.')
+	if next == -1 {
+		return -1
+	}
+	return i + 1 + next
+}
+
+func logValueType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return LogTypeString
+	case float64:
+		return LogTypeNumber
+	case bool:
+		return LogTypeBoolean
+	case map[string]interface{}:
+		return LogTypeObject
+	case []interface{}:
+		return LogTypeArray
+	default:
+		return reflect.TypeOf(value).String()
+	}
+}
+
+func logTypeMatches(expected, actual string, value interface{}) bool {
+	switch expected {
+	case "", LogTypeAny:
+		return true
+	case LogTypeInteger:
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	default:
+		return expected == actual
+	}
+}
+
+func logEnumContains(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateLogSchema(schemaPath string, violations []LogViolation, unmarshalErrors int) error {
+	fmt.Println("++++++++++++++++++++++++++++++++++++++++++++++++++++++++")
+	if _, err := LoadLogSchema(schemaPath); err != nil {
+		fmt.Println("please provide valid log schema", schemaPath)
+		return err
+	}
+
+	var failed bool
+	if len(violations) != 0 {
+		printViolations(violations)
+		failed = true
+	}
+	if unmarshalErrors != 0 {
+		fmt.Println("found json unmarshal errors: ", unmarshalErrors)
+		failed = true
+	}
+
+	if failed {
+		return fmt.Errorf("validation of log schema failed, please check the output")
+	}
+	return nil
+}
+
+func printViolations(violations []LogViolation) {
+	fmt.Println("=================================================")
+	fmt.Printf("Log lines do not match the schema, %d violations\n", len(violations))
+	for i, v := range violations {
+		if i == maxPrintedViolations {
+			fmt.Printf("... and %d more\n", len(violations)-maxPrintedViolations)
+			break
+		}
+		text := v.Text
+		if len(text) > maxViolationTextLen {
+			text = text[:maxViolationTextLen] + "..."
+		}
+		fmt.Printf("line %d: %s: %s\n    %s\n", v.Line, v.Field, v.Message, text)
+	}
+	fmt.Println("=================================================")
+}
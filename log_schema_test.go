@@ -0,0 +1,114 @@
+package goat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testLogSchema = `{
+  "fields": {
+    "level":      {"type": "string", "enum": ["info", "error"], "required": true},
+    "ts":         {"type": "string", "format": "rfc3339", "required": true},
+    "logger":     {"type": "string"},
+    "msg":        {"type": "string"},
+    "error":      {"type": "string"},
+    "request.id": {"type": "string", "format": "uuid", "nullable": true},
+    "payload":    {"type": "object"}
+  },
+  "rules": [{"when": {"level": "error"}, "require": ["error"]}],
+  "logger_field": "logger",
+  "loggers": {"http": {"fields": {"status": {"type": "integer", "required": true}}}}
+}`
+
+func TestLogSchemaValidate(t *testing.T) {
+	schema, err := ParseLogSchema([]byte(testLogSchema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		line string
+		name string
+		want []LogViolation
+	}{
+		{
+			name: "valid",
+			line: `{"level":"info","ts":"2024-01-02T03:04:05.123Z","request":{"id":"0b5e1a7c-3f4d-4c7a-9a51-1c2d3e4f5a6b"},"payload":{"any":1}}`,
+		},
+		{
+			name: "dotted key and null",
+			line: `{"level":"info","ts":"2024-01-02T03:04:05Z","request.id":null}`,
+		},
+		{
+			name: "types, enums and formats",
+			line: `{"level":"warn","ts":"yesterday","request":{"id":"42","ip":"127.0.0.1"},"msg":1}`,
+			want: []LogViolation{
+				{Field: "level", Message: "value warn is not one of [info error]"},
+				{Field: "msg", Message: "type is number, expected string"},
+				{Field: "request.id", Message: `value "42" is not a UUID`},
+				{Field: "request.ip", Message: "unknown field of type string"},
+				{Field: "ts", Message: `value "yesterday" is not an RFC3339 timestamp`},
+			},
+		},
+		{
+			name: "required and rules",
+			line: `{"level":"error","msg":null}`,
+			want: []LogViolation{
+				{Field: "error", Message: "required field is missing when level=error"},
+				{Field: "msg", Message: "is null"},
+				{Field: "ts", Message: "required field is missing"},
+			},
+		},
+		{
+			name: "per logger schema",
+			line: `{"level":"info","ts":"2024-01-02T03:04:05Z","logger":"http","status":200.5}`,
+			want: []LogViolation{
+				{Field: "status", Message: "type is number, expected integer"},
+			},
+		},
+		{
+			name: "logger fields are not common",
+			line: `{"level":"info","ts":"2024-01-02T03:04:05Z","logger":"db","status":200}`,
+			want: []LogViolation{
+				{Field: "status", Message: "unknown field of type number"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var line map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.line), &line))
+			require.Equal(t, tt.want, schema.Validate(line))
+		})
+	}
+}
+
+func TestParseLogSchemaErrors(t *testing.T) {
+	_, err := ParseLogSchema([]byte(`{"fields": {"ts": {"type": "date"}}}`))
+	require.ErrorContains(t, err, `field ts: unknown type "date"`)
+
+	_, err = ParseLogSchema([]byte(`{"fields": {"ts": {"format": "unix"}}}`))
+	require.ErrorContains(t, err, `field ts: unknown format "unix"`)
+
+	_, err = ParseLogSchema([]byte(`{"loggers": {"http": {}}}`))
+	require.ErrorContains(t, err, "logger_field is required")
+}
+
+func TestFieldsCollectorSchemaViolations(t *testing.T) {
+	schema, err := ParseLogSchema([]byte(testLogSchema))
+	require.NoError(t, err)
+
+	fc := newFieldsCollector()
+	fc.schema = schema
+	_, err = fc.Write([]byte("{\"level\":\"info\",\"ts\":\"2024-01-02T03:04:05Z\"}\nnot json\n{\"level\":\"error\","))
+	require.NoError(t, err)
+	_, err = fc.Write([]byte("\"ts\":\"2024-01-02T03:04:05Z\"}\n"))
+	require.NoError(t, err)
+
+	require.Equal(t, 1, fc.unmarshalErrors)
+	require.Equal(t, []LogViolation{{
+		Field:   "error",
+		Message: "required field is missing when level=error",
+		Text:    `{"level":"error","ts":"2024-01-02T03:04:05Z"}`,
+		Line:    3,
+	}}, fc.violations)
+}
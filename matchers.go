@@ -1,8 +1,11 @@
 package goat
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
+	"go.uber.org/mock/gomock"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -23,3 +26,106 @@ func (r ProtoMatcher) Matches(msg interface{}) bool {
 func (r ProtoMatcher) String() string {
 	return fmt.Sprintf("is %s", r.Msg)
 }
+
+// Capture implements the gomock.Matcher interface, it matches arguments of type T and stores them.
+//
+// gomock checks arguments of every candidate expectation, so an argument is captured when this matcher
+// is asked, even if another argument of the same call does not match. Use one capture per expectation
+// with specific matchers for the other arguments.
+//
+// Example:
+//
+//	orderID := gtt.NewCapture[string]()
+//	mocks.Billing.EXPECT().Charge(gomock.Any(), orderID).Return(nil)
+//	...
+//	id, err := orderID.Wait(ctx)
+type Capture[T any] struct {
+	inner   gomock.Matcher
+	changed chan struct{}
+	values  []T
+	m       sync.Mutex
+}
+
+// NewCapture returns a capture matching any argument of type T or arguments matching the inner matcher.
+func NewCapture[T any](inner ...gomock.Matcher) *Capture[T] {
+	c := &Capture[T]{
+		changed: make(chan struct{}),
+	}
+	if len(inner) > 0 {
+		c.inner = gomock.All(inner...)
+	}
+	return c
+}
+
+func (c *Capture[T]) Matches(x interface{}) bool {
+	v, ok := x.(T)
+	if !ok {
+		return false
+	}
+	if c.inner != nil && !c.inner.Matches(x) {
+		return false
+	}
+
+	c.m.Lock()
+	c.values = append(c.values, v)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.m.Unlock()
+	return true
+}
+
+func (c *Capture[T]) String() string {
+	var zero T
+	if c.inner == nil {
+		return fmt.Sprintf("is captured %T", zero)
+	}
+	return fmt.Sprintf("is captured %T and %s", zero, c.inner)
+}
+
+// Last returns the last captured argument, false if nothing is captured.
+func (c *Capture[T]) Last() (T, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if len(c.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return c.values[len(c.values)-1], true
+}
+
+// All returns all captured arguments in order.
+func (c *Capture[T]) All() []T {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return append([]T(nil), c.values...)
+}
+
+// Wait blocks until an argument is captured and returns the last one.
+func (c *Capture[T]) Wait(ctx context.Context) (T, error) {
+	values, err := c.WaitN(ctx, 1)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return values[len(values)-1], nil
+}
+
+// WaitN blocks until at least n arguments are captured and returns all of them.
+func (c *Capture[T]) WaitN(ctx context.Context, n int) ([]T, error) {
+	for {
+		c.m.Lock()
+		if len(c.values) >= n {
+			values := append([]T(nil), c.values...)
+			c.m.Unlock()
+			return values, nil
+		}
+		changed, got := c.changed, len(c.values)
+		c.m.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("captured %d of %d arguments: %w", got, n, ctx.Err())
+		}
+	}
+}
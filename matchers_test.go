@@ -0,0 +1,58 @@
+package goat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCapture(t *testing.T) {
+	c := NewCapture[string]()
+	_, ok := c.Last()
+	require.False(t, ok)
+
+	require.False(t, c.Matches(42))
+	require.True(t, c.Matches("ord_1"))
+	require.True(t, c.Matches("ord_2"))
+
+	last, ok := c.Last()
+	require.True(t, ok)
+	require.Equal(t, "ord_2", last)
+	require.Equal(t, []string{"ord_1", "ord_2"}, c.All())
+
+	filtered := NewCapture[string](gomock.Eq("ord_1"))
+	require.False(t, filtered.Matches("ord_2"))
+	require.True(t, filtered.Matches("ord_1"))
+	require.Equal(t, []string{"ord_1"}, filtered.All())
+	require.Contains(t, filtered.String(), "ord_1")
+}
+
+func TestCaptureWait(t *testing.T) {
+	c := NewCapture[int]()
+
+	go func() {
+		for i := 1; i <= 3; i++ {
+			time.Sleep(10 * time.Millisecond)
+			c.Matches(i)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	v, err := c.Wait(ctx)
+	require.NoError(t, err)
+	require.Positive(t, v)
+
+	values, err := c.WaitN(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, values)
+
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer shortCancel()
+	_, err = c.WaitN(shortCtx, 4)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,253 @@
+package goat
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Educentr/goat/wait"
+)
+
+// Migration tool names for MigrationWaitConfigByName.
+const (
+	MigrationToolPostgresql    = "postgres"
+	MigrationToolMysql         = "mysql"
+	MigrationToolGolangMigrate = "golang-migrate"
+	MigrationToolGoose         = "goose"
+	MigrationToolAtlas         = "atlas"
+	MigrationToolFlyway        = "flyway"
+	MigrationToolClickHouse    = "clickhouse"
+)
+
+const (
+	GolangMigrateVersionQuery = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	GooseVersionQuery         = `SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC LIMIT 1`
+	AtlasRevisionQuery        = `SELECT version, applied, total, COALESCE(error, '') FROM atlas_schema_revisions.atlas_schema_revisions ORDER BY executed_at DESC LIMIT 1`
+	FlywayHistoryQuery        = `SELECT version, success FROM flyway_schema_history WHERE version IS NOT NULL ORDER BY installed_rank DESC LIMIT 1`
+	// ClickHouseMigrationVersionQuery reads the golang-migrate table of ClickHouse, it is append-only with sequence column
+	ClickHouseMigrationVersionQuery = `SELECT version, dirty FROM schema_migrations ORDER BY sequence DESC LIMIT 1`
+)
+
+// GolangMigrateWaitConfig checks the golang-migrate table, a dirty migration fails waiting immediately.
+// It is used for ClickHouse too, the table of ClickHouse keeps the history ordered by sequence.
+type GolangMigrateWaitConfig struct {
+	ExpectedVersion string
+	WaitConfigBase
+}
+
+// GooseWaitConfig checks the goose_db_version table, the last record must be applied.
+type GooseWaitConfig struct {
+	ExpectedVersion string
+	WaitConfigBase
+}
+
+// AtlasWaitConfig checks the last Atlas revision, a revision with error fails waiting immediately.
+// Query must be changed for MySQL, there the table is in the connected database: atlas_schema_revisions.
+type AtlasWaitConfig struct {
+	ExpectedVersion string
+	WaitConfigBase
+}
+
+// FlywayWaitConfig checks the last versioned migration of flyway_schema_history, a failed migration fails waiting immediately.
+type FlywayWaitConfig struct {
+	ExpectedVersion string
+	WaitConfigBase
+}
+
+// MigrationWaitConfigByName returns the default config of the migration tool, see MigrationTool constants.
+// Versions are compared as dot separated numbers, e.g. "20240101120000" or "1.10.2".
+func MigrationWaitConfigByName(name, expectedVersion string) (MigrationWaitConfig, error) {
+	switch name {
+	case MigrationToolPostgresql:
+		version, err := strconv.Atoi(expectedVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected version %q: %w", expectedVersion, err)
+		}
+		return PostgresqlDefault(version), nil
+	case MigrationToolMysql:
+		return MysqlDefault(expectedVersion), nil
+	case MigrationToolGolangMigrate:
+		return GolangMigrateDefault(expectedVersion), nil
+	case MigrationToolGoose:
+		return GooseDefault(expectedVersion), nil
+	case MigrationToolAtlas:
+		return AtlasDefault(expectedVersion), nil
+	case MigrationToolFlyway:
+		return FlywayDefault(expectedVersion), nil
+	case MigrationToolClickHouse:
+		return ClickHouseDefault(expectedVersion), nil
+	default:
+		return nil, fmt.Errorf("unknown migration tool %q", name)
+	}
+}
+
+func defaultWaitConfigBase(query string) WaitConfigBase {
+	return WaitConfigBase{
+		Query:    query,
+		Interval: time.Second,
+		Cycles:   defaultCycles,
+	}
+}
+
+func GolangMigrateDefault(expectedVersion string) MigrationWaitConfig {
+	return &GolangMigrateWaitConfig{
+		WaitConfigBase:  defaultWaitConfigBase(GolangMigrateVersionQuery),
+		ExpectedVersion: expectedVersion,
+	}
+}
+
+func ClickHouseDefault(expectedVersion string) MigrationWaitConfig {
+	return &GolangMigrateWaitConfig{
+		WaitConfigBase:  defaultWaitConfigBase(ClickHouseMigrationVersionQuery),
+		ExpectedVersion: expectedVersion,
+	}
+}
+
+func GooseDefault(expectedVersion string) MigrationWaitConfig {
+	return &GooseWaitConfig{
+		WaitConfigBase:  defaultWaitConfigBase(GooseVersionQuery),
+		ExpectedVersion: expectedVersion,
+	}
+}
+
+func AtlasDefault(expectedVersion string) MigrationWaitConfig {
+	return &AtlasWaitConfig{
+		WaitConfigBase:  defaultWaitConfigBase(AtlasRevisionQuery),
+		ExpectedVersion: expectedVersion,
+	}
+}
+
+func FlywayDefault(expectedVersion string) MigrationWaitConfig {
+	return &FlywayWaitConfig{
+		WaitConfigBase:  defaultWaitConfigBase(FlywayHistoryQuery),
+		ExpectedVersion: expectedVersion,
+	}
+}
+
+func (g *GolangMigrateWaitConfig) Check(rows *sql.Rows) error {
+	var (
+		version string
+		dirty   interface{}
+	)
+	if err := rows.Scan(&version, &dirty); err != nil {
+		return err
+	}
+
+	if sqlBool(dirty) {
+		return wait.Permanent(fmt.Errorf("migration step failed, version %s is dirty", version))
+	}
+
+	return checkVersion(version, g.ExpectedVersion)
+}
+
+func (g *GooseWaitConfig) Check(rows *sql.Rows) error {
+	var (
+		version string
+		applied interface{}
+	)
+	if err := rows.Scan(&version, &applied); err != nil {
+		return err
+	}
+
+	if !sqlBool(applied) {
+		return fmt.Errorf("migration step failed, version %s is rolled back", version)
+	}
+
+	return checkVersion(version, g.ExpectedVersion)
+}
+
+func (a *AtlasWaitConfig) Check(rows *sql.Rows) error {
+	var (
+		version        string
+		applied, total int
+		revisionErr    string
+	)
+	if err := rows.Scan(&version, &applied, &total, &revisionErr); err != nil {
+		return err
+	}
+
+	if revisionErr != "" {
+		return wait.Permanent(fmt.Errorf("migration step failed, version %s: %s", version, revisionErr))
+	}
+	if applied < total {
+		return fmt.Errorf("migration step failed, version %s applied %d of %d statements", version, applied, total)
+	}
+
+	return checkVersion(version, a.ExpectedVersion)
+}
+
+func (f *FlywayWaitConfig) Check(rows *sql.Rows) error {
+	var (
+		version string
+		success interface{}
+	)
+	if err := rows.Scan(&version, &success); err != nil {
+		return err
+	}
+
+	if !sqlBool(success) {
+		return wait.Permanent(fmt.Errorf("migration step failed, version %s is not successful", version))
+	}
+
+	return checkVersion(version, f.ExpectedVersion)
+}
+
+func checkVersion(version, expected string) error {
+	if compareVersions(version, expected) < 0 {
+		return fmt.Errorf("migration step failed, version is %s, expected %s", version, expected)
+	}
+
+	return nil
+}
+
+// compareVersions compares dot or underscore separated versions part by part, numeric parts are compared as numbers,
+// missing parts are zero.
+func compareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(r rune) bool { return r == '.' || r == '_' })
+	}
+	pa, pb := split(a), split(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := "0", "0"
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		xn, xErr := strconv.ParseUint(x, 10, 64)
+		yn, yErr := strconv.ParseUint(y, 10, 64)
+		switch {
+		case xErr == nil && yErr == nil && xn != yn:
+			if xn < yn {
+				return -1
+			}
+			return 1
+		case (xErr != nil || yErr != nil) && x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// sqlBool converts boolean columns of different drivers: bool, integers, "t"/"true"/"1" strings.
+func sqlBool(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case int64:
+		return b != 0
+	case uint8:
+		return b != 0
+	case []byte:
+		parsed, _ := strconv.ParseBool(string(b)) //nolint:errcheck // unknown values are false
+		return parsed
+	case string:
+		parsed, _ := strconv.ParseBool(b) //nolint:errcheck // unknown values are false
+		return parsed
+	default:
+		return false
+	}
+}
@@ -0,0 +1,143 @@
+package goat
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/goat/wait"
+)
+
+// fakeRowsDriver returns rows registered for the DSN to any query.
+type (
+	fakeRowsDriver struct {
+		results map[string]fakeRows
+		m       sync.Mutex
+	}
+	fakeRows struct {
+		columns []string
+		values  [][]driver.Value
+		pos     int
+	}
+	fakeRowsConn struct {
+		rows fakeRows
+	}
+)
+
+var fakeDriver = &fakeRowsDriver{results: make(map[string]fakeRows)}
+
+func init() {
+	sql.Register("goat-fake-rows", fakeDriver)
+}
+
+func fakeDB(t *testing.T, columns []string, values ...[]driver.Value) *sql.DB {
+	t.Helper()
+	fakeDriver.m.Lock()
+	fakeDriver.results[t.Name()] = fakeRows{columns: columns, values: values}
+	fakeDriver.m.Unlock()
+
+	db, err := sql.Open("goat-fake-rows", t.Name())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func (d *fakeRowsDriver) Open(dsn string) (driver.Conn, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return &fakeRowsConn{rows: d.results[dsn]}, nil
+}
+
+func (c *fakeRowsConn) Prepare(string) (driver.Stmt, error) { return c, nil }
+func (c *fakeRowsConn) Close() error                        { return nil }
+func (c *fakeRowsConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (c *fakeRowsConn) NumInput() int                       { return -1 }
+func (c *fakeRowsConn) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeRowsConn) Query([]driver.Value) (driver.Rows, error) {
+	rows := c.rows
+	return &rows, nil
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
+
+func TestMigrationDialects(t *testing.T) {
+	tests := []struct {
+		name      string
+		tool      string
+		expected  string
+		columns   []string
+		row       []driver.Value
+		wantErr   bool
+		permanent bool
+	}{
+		{name: "golang-migrate", tool: MigrationToolGolangMigrate, expected: "3", columns: []string{"version", "dirty"}, row: []driver.Value{int64(3), false}},
+		{name: "golang-migrate old", tool: MigrationToolGolangMigrate, expected: "3", columns: []string{"version", "dirty"}, row: []driver.Value{int64(2), false}, wantErr: true},
+		{name: "golang-migrate dirty", tool: MigrationToolGolangMigrate, expected: "3", columns: []string{"version", "dirty"}, row: []driver.Value{int64(3), true}, wantErr: true, permanent: true},
+		{name: "clickhouse", tool: MigrationToolClickHouse, expected: "20240101", columns: []string{"version", "dirty"}, row: []driver.Value{int64(20240102), int64(0)}},
+		{name: "goose", tool: MigrationToolGoose, expected: "5", columns: []string{"version_id", "is_applied"}, row: []driver.Value{int64(5), []byte("t")}},
+		{name: "goose rolled back", tool: MigrationToolGoose, expected: "5", columns: []string{"version_id", "is_applied"}, row: []driver.Value{int64(5), false}, wantErr: true},
+		{name: "atlas", tool: MigrationToolAtlas, expected: "20240101120000", columns: []string{"version", "applied", "total", "error"}, row: []driver.Value{"20240101120000", int64(3), int64(3), ""}},
+		{name: "atlas partial", tool: MigrationToolAtlas, expected: "20240101120000", columns: []string{"version", "applied", "total", "error"}, row: []driver.Value{"20240101120000", int64(1), int64(3), ""}, wantErr: true},
+		{name: "atlas error", tool: MigrationToolAtlas, expected: "20240101120000", columns: []string{"version", "applied", "total", "error"}, row: []driver.Value{"20240101120000", int64(1), int64(3), "syntax error"}, wantErr: true, permanent: true},
+		{name: "flyway", tool: MigrationToolFlyway, expected: "1.2", columns: []string{"version", "success"}, row: []driver.Value{"1.10", true}},
+		{name: "flyway failed", tool: MigrationToolFlyway, expected: "1.2", columns: []string{"version", "success"}, row: []driver.Value{"1.10", false}, wantErr: true, permanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := MigrationWaitConfigByName(tt.tool, tt.expected)
+			require.NoError(t, err)
+			cfg.SetCycles(2)
+			cfg.SetInterval(0)
+
+			db := fakeDB(t, tt.columns, tt.row)
+			err = WaitAppMigration(db, cfg)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				require.NoError(t, WaitAppMigrationContext(context.Background(), db, cfg))
+				return
+			}
+			require.Error(t, err)
+			require.Equal(t, tt.permanent, wait.IsPermanent(err))
+		})
+	}
+
+	_, err := MigrationWaitConfigByName("liquibase", "1")
+	require.Error(t, err)
+}
+
+func TestWaitAppMigrationLastError(t *testing.T) {
+	cfg := PostgresqlDefault(7)
+	cfg.SetCycles(2)
+	cfg.SetInterval(0)
+
+	err := WaitAppMigration(fakeDB(t, []string{"version"}, []driver.Value{int64(6)}), cfg)
+	require.ErrorContains(t, err, "too long: migration step failed, version is 6, expected 7")
+
+	err = WaitAppMigration(fakeDB(t, []string{"version"}), cfg)
+	require.ErrorContains(t, err, "empty migrations table")
+}
+
+func TestCompareVersions(t *testing.T) {
+	require.Equal(t, 0, compareVersions("1.2.0", "1.2"))
+	require.Equal(t, -1, compareVersions("1.2", "1.10"))
+	require.Equal(t, 1, compareVersions("20240102", "20240101"))
+	require.Equal(t, -1, compareVersions("2", "10"))
+}
@@ -0,0 +1,225 @@
+package goat
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/mock/gomock"
+)
+
+const expectationsPollInterval = 10 * time.Millisecond
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// Expectation is a gomock call tracked by MocksHandler, calls are counted by an action of the call,
+// so waiting for it and reporting it do not depend on gomock internals.
+// Its methods mirror gomock.Call, set the number of calls on the Expectation to let it know the number.
+type Expectation struct {
+	*gomock.Call
+	calls    atomic.Int64
+	minCalls atomic.Int64
+}
+
+// Expect tracks the call recorded for the mock method, the method gives the signature of the call:
+//
+//	charge := mocks.Expect(billing.Charge, billing.EXPECT().Charge(gomock.Any(), gomock.Any())).
+//		Return(&pb.ChargeResponse{}, nil).
+//		Times(2)
+//	// ... trigger the app
+//	require.NoError(t, mocks.Eventually(ctx, charge))
+//
+// WaitForExpectations and Eventually list tracked calls that are not made on timeout.
+// It panics if method is not a function.
+func (m *MocksHandler) Expect(method interface{}, call *gomock.Call) *Expectation {
+	mt := reflect.TypeOf(method)
+	if mt == nil || mt.Kind() != reflect.Func {
+		panic(fmt.Sprintf("method of the expectation should be a function, got %T", method))
+	}
+	ins := make([]reflect.Type, mt.NumIn())
+	for i := range ins {
+		ins[i] = anyType
+	}
+	if mt.IsVariadic() {
+		ins[len(ins)-1] = reflect.SliceOf(anyType)
+	}
+
+	e := &Expectation{Call: call}
+	e.minCalls.Store(1)
+	counter := reflect.MakeFunc(reflect.FuncOf(ins, nil, mt.IsVariadic()), func([]reflect.Value) []reflect.Value {
+		e.calls.Add(1)
+		return nil
+	})
+	call.Do(counter.Interface())
+
+	m.mu.Lock()
+	m.expectations = append(m.expectations, e)
+	m.mu.Unlock()
+	return e
+}
+
+// Count returns the number of calls made
+func (e *Expectation) Count() int {
+	return int(e.calls.Load())
+}
+
+// Done reports whether the minimal number of calls is made
+func (e *Expectation) Done() bool {
+	return e.calls.Load() >= e.minCalls.Load()
+}
+
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s: %d of %d calls", e.Call, e.calls.Load(), e.minCalls.Load())
+}
+
+// Times sets the exact number of calls
+func (e *Expectation) Times(n int) *Expectation {
+	e.Call.Times(n)
+	e.minCalls.Store(int64(n))
+	return e
+}
+
+// MinTimes sets the minimal number of calls
+func (e *Expectation) MinTimes(n int) *Expectation {
+	e.Call.MinTimes(n)
+	e.minCalls.Store(int64(n))
+	return e
+}
+
+// MaxTimes sets the maximal number of calls
+func (e *Expectation) MaxTimes(n int) *Expectation {
+	e.Call.MaxTimes(n)
+	if int64(n) < e.minCalls.Load() {
+		// gomock lowers the minimum for MaxTimes(0)
+		e.minCalls.Store(int64(n))
+	}
+	return e
+}
+
+// AnyTimes allows any number of calls, the expectation is done at once
+func (e *Expectation) AnyTimes() *Expectation {
+	e.Call.AnyTimes()
+	e.minCalls.Store(0)
+	return e
+}
+
+// Return declares the values returned by the call
+func (e *Expectation) Return(rets ...interface{}) *Expectation {
+	e.Call.Return(rets...)
+	return e
+}
+
+// Do declares the action run when the call is matched
+func (e *Expectation) Do(f interface{}) *Expectation {
+	e.Call.Do(f)
+	return e
+}
+
+// DoAndReturn declares the action run when the call is matched, its results are returned by the call
+func (e *Expectation) DoAndReturn(f interface{}) *Expectation {
+	e.Call.DoAndReturn(f)
+	return e
+}
+
+// SetArg declares the action setting the nth argument
+func (e *Expectation) SetArg(n int, value interface{}) *Expectation {
+	e.Call.SetArg(n, value)
+	return e
+}
+
+// After declares that the call may only match after preReq
+func (e *Expectation) After(preReq *gomock.Call) *Expectation {
+	e.Call.After(preReq)
+	return e
+}
+
+// WaitForExpectations blocks until all gomock expectations of the mocks are satisfied.
+// It is used when the app calls mocks asynchronously, mock servers are kept running.
+// On timeout the error lists tracked expectations that are not done, see Expect.
+func (m *MocksHandler) WaitForExpectations(ctx context.Context) error {
+	return poll(ctx, func() (bool, string) {
+		if m.Controller().Satisfied() {
+			return true, ""
+		}
+		pending := m.PendingExpectations()
+		if len(pending) == 0 {
+			return false, "expectations are not satisfied"
+		}
+		return false, "expectations are not satisfied:\n" + strings.Join(pending, "\n")
+	})
+}
+
+// Eventually blocks until the calls are made, other expectations of the mocks are not checked.
+// Calls are *Expectation or *gomock.Call values tracked by Expect, on timeout the error lists calls that are not made.
+func (m *MocksHandler) Eventually(ctx context.Context, calls ...interface{}) error {
+	expectations := make([]*Expectation, 0, len(calls))
+	for _, c := range calls {
+		e, err := m.expectation(c)
+		if err != nil {
+			return err
+		}
+		expectations = append(expectations, e)
+	}
+	return poll(ctx, func() (bool, string) {
+		var pending []string
+		for _, e := range expectations {
+			if !e.Done() {
+				pending = append(pending, e.String())
+			}
+		}
+		return len(pending) == 0, fmt.Sprintf("%d calls are not made:\n%s", len(pending), strings.Join(pending, "\n"))
+	})
+}
+
+// PendingExpectations describes tracked expectations of the current controller that are not done
+func (m *MocksHandler) PendingExpectations() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []string
+	for _, e := range m.expectations {
+		if !e.Done() {
+			pending = append(pending, e.String())
+		}
+	}
+	return pending
+}
+
+func (m *MocksHandler) expectation(call interface{}) (*Expectation, error) {
+	switch c := call.(type) {
+	case *Expectation:
+		return c, nil
+	case *gomock.Call:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, e := range m.expectations {
+			if e.Call == c {
+				return e, nil
+			}
+		}
+		return nil, fmt.Errorf("call %s is not tracked, record it with MocksHandler.Expect", c)
+	default:
+		return nil, fmt.Errorf("%T is not a gomock call", call)
+	}
+}
+
+// poll calls check until it is done, on timeout the error has the message of the last check
+func poll(ctx context.Context, check func() (done bool, msg string)) error {
+	ticker := time.NewTicker(expectationsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		done, msg := check()
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%s\n%w", msg, ctx.Err())
+		}
+	}
+}
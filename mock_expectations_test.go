@@ -0,0 +1,63 @@
+package goat
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type notifierMock struct {
+	ctrl *gomock.Controller
+}
+
+func (n *notifierMock) Notify(id string) {
+	n.ctrl.T.Helper()
+	n.ctrl.Call(n, "Notify", id)
+}
+
+func (n *notifierMock) expectNotify(id interface{}) *gomock.Call {
+	n.ctrl.T.Helper()
+	return n.ctrl.RecordCallWithMethodType(n, "Notify", reflect.TypeOf((*notifierMock)(nil).Notify), id)
+}
+
+func TestMocksHandlerWaitForExpectations(t *testing.T) {
+	mocks := NewMocksHandler(t, nil, nil)
+	notifier := &notifierMock{ctrl: mocks.Controller()}
+
+	first := mocks.Expect(notifier.Notify, notifier.expectNotify("a"))
+	second := notifier.expectNotify("b")
+	tracked := mocks.Expect(notifier.Notify, second).Times(2)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		notifier.Notify("a")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, mocks.Eventually(ctx, first))
+
+	notifier.Notify("b")
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	err := mocks.WaitForExpectations(shortCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, err.Error(), "expectations are not satisfied:\n*goat.notifierMock.Notify(is equal to b (string))")
+	require.Contains(t, err.Error(), "1 of 2 calls")
+	require.NotContains(t, err.Error(), "is equal to a")
+	err = mocks.Eventually(shortCtx, first, second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, err.Error(), "1 calls are not made:\n*goat.notifierMock.Notify(is equal to b (string))")
+
+	err = mocks.Eventually(ctx, notifier.expectNotify("c").AnyTimes())
+	require.ErrorContains(t, err, "is not tracked")
+
+	go notifier.Notify("b")
+	require.NoError(t, mocks.WaitForExpectations(ctx))
+	require.NoError(t, mocks.Eventually(ctx, first, second))
+	require.Equal(t, 2, tracked.Count())
+}
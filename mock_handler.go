@@ -1,8 +1,11 @@
 package goat
 
 import (
+	"fmt"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 
 	env "github.com/caarlos0/env/v8"
@@ -10,79 +13,295 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// DefaultHTTPMockName is the name of the HTTP mock server created from the HTTPCB passed to NewMocksHandler
+	DefaultHTTPMockName = "http"
+	// DefaultGRPCMockName is the name of the gRPC mock server created from the GrpcCB passed to NewMocksHandler
+	DefaultGRPCMockName = "grpc"
 )
 
 type MocksHandler struct {
-	ctl             *gomock.Controller
-	grpcMockHandler *GRPCMockHandler
-	httpMockHandler *HTTPMockHandler
+	ctl       *gomock.Controller
+	grpcMocks map[string]*GRPCMockHandler
+	httpMocks map[string]*HTTPMockHandler
+	certs     *MockCertificates
+	// callbacks are kept to register mocks with a new controller by bind
+	grpcCBs map[string]GrpcCB
+	httpCBs map[string]HTTPCB
+	// expectations are tracked by Expect for the current controller
+	expectations []*Expectation
+	mu           sync.Mutex
 }
 
 type MocksConfig struct {
-	GrpcMockAddress  string `env:"GRPC_MOCK_ADDRESS" envDefault:"127.0.0.1:9191"`
-	HTTPMockAddress  string `env:"HTTP_MOCK_ADDRESS" envDefault:"127.0.0.1:9898"`
+	GrpcMockAddress  string `env:"GRPC_MOCK_ADDRESS" envDefault:"127.0.0.1:0"`
+	HTTPMockAddress  string `env:"HTTP_MOCK_ADDRESS" envDefault:"127.0.0.1:0"`
 	GrpcListenSchema string `env:"GRPC_LISTEN_SCHEMA" envDefault:"tcp"`
 	HTTPListenSchema string `env:"HTTP_LISTEN_SCHEMA" envDefault:"tcp"`
+	// MockAddresses overrides addresses of named mock servers, e.g. "payments=127.0.0.1:9000,users=127.0.0.1:9001".
+	// Named servers without an address listen on a random port.
+	MockAddresses []string `env:"MOCK_ADDRESSES" envSeparator:","`
+	// MockCertsDir is where generated certificates are written, test temp dir by default
+	MockCertsDir string `env:"MOCK_CERTS_DIR"`
+	// MockClientCAFile is an extra CA to verify client certificates with in mTLS mode
+	MockClientCAFile string `env:"MOCK_CLIENT_CA_FILE"`
+	// MockCertHosts are hosts the generated server certificate is valid for
+	MockCertHosts []string `env:"MOCK_CERT_HOSTS" envDefault:"127.0.0.1,localhost" envSeparator:","`
+	GrpcMockTLS   bool     `env:"GRPC_MOCK_TLS"`
+	HTTPMockTLS   bool     `env:"HTTP_MOCK_TLS"`
+	// MockClientAuth requires and verifies client certificates on TLS mock servers
+	MockClientAuth bool `env:"MOCK_CLIENT_AUTH"`
 }
 
 type GrpcCB func(server *grpc.Server, ctl *gomock.Controller)
 type HTTPCB func(server *http.ServeMux, ctl *gomock.Controller)
 
+// MocksOption configures additional mock servers of MocksHandler.
+type MocksOption func(o *mocksOptions)
+
+type mocksOptions struct {
+	http        map[string]HTTPCB
+	grpc        map[string]GrpcCB
+	randomPorts bool
+}
+
+// WithHTTPMock adds a named HTTP mock server, e.g. one per mocked partner.
+func WithHTTPMock(name string, cb HTTPCB) MocksOption {
+	return func(o *mocksOptions) {
+		o.http[name] = cb
+	}
+}
+
+// WithGRPCMock adds a named gRPC mock server.
+func WithGRPCMock(name string, cb GrpcCB) MocksOption {
+	return func(o *mocksOptions) {
+		o.grpc[name] = cb
+	}
+}
+
+// WithRandomPorts makes all mock servers listen on random ports, ignoring configured addresses,
+// so handlers of parallel tests never collide.
+func WithRandomPorts() MocksOption {
+	return func(o *mocksOptions) {
+		o.randomPorts = true
+	}
+}
+
 // NewMocksHandler creates a new MocksHandler with HTTP and gRPC mock servers.
-func NewMocksHandler(t *testing.T, gCb GrpcCB, hCb HTTPCB) *MocksHandler {
+// Servers listen on random ports by default, use Addr to get the resolved address.
+func NewMocksHandler(t *testing.T, gCb GrpcCB, hCb HTTPCB, opts ...MocksOption) *MocksHandler {
 	cfg := &MocksConfig{}
-	opts := env.Options{
+	err := env.ParseWithOptions(cfg, env.Options{
 		Prefix: "GOAT_",
+	})
+	require.NoError(t, err, "failed to parse mocks config")
+
+	o := &mocksOptions{
+		http: make(map[string]HTTPCB),
+		grpc: make(map[string]GrpcCB),
 	}
-	err := env.ParseWithOptions(cfg, opts)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	addresses, err := parseMockAddresses(cfg.MockAddresses)
 	require.NoError(t, err, "failed to parse mocks config")
 
+	// Only create default mock handlers if callback is provided
+	if gCb != nil {
+		o.grpc[DefaultGRPCMockName] = gCb
+		addresses[DefaultGRPCMockName] = cfg.GrpcMockAddress
+	}
+	if hCb != nil {
+		o.http[DefaultHTTPMockName] = hCb
+		addresses[DefaultHTTPMockName] = cfg.HTTPMockAddress
+	}
+	if o.randomPorts {
+		clear(addresses)
+	}
+
+	for name := range o.http {
+		_, ok := o.grpc[name]
+		require.False(t, ok, "mock server name %q is used by both HTTP and gRPC mocks", name)
+	}
+
 	h := &MocksHandler{
-		ctl: gomock.NewController(t),
+		ctl:       gomock.NewController(t),
+		grpcMocks: make(map[string]*GRPCMockHandler, len(o.grpc)),
+		httpMocks: make(map[string]*HTTPMockHandler, len(o.http)),
+		grpcCBs:   o.grpc,
+		httpCBs:   o.http,
 	}
 
-	// Only create gRPC mock handler if callback is provided
-	if gCb != nil {
-		h.grpcMockHandler, err = NewGRPCMockHandler(cfg.GrpcListenSchema, cfg.GrpcMockAddress, func(server *grpc.Server) {
-			gCb(server, h.ctl)
-		})
-		require.NoError(t, err, "failed to create gRPC mock handler")
+	if cfg.GrpcMockTLS || cfg.HTTPMockTLS {
+		h.certs = newMockCertificates(t, cfg)
 	}
 
-	// Only create HTTP mock handler if callback is provided
-	if hCb != nil {
-		h.httpMockHandler, err = NewHTTPMockHandler(cfg.HTTPListenSchema, cfg.HTTPMockAddress, func(server *http.ServeMux) {
-			hCb(server, h.ctl)
+	for name, cb := range o.grpc {
+		var serverOpts []grpc.ServerOption
+		if cfg.GrpcMockTLS {
+			serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(h.certs.ServerTLSConfig(cfg.MockClientAuth))))
+		}
+		h.grpcMocks[name], err = NewGRPCMockHandler(cfg.GrpcListenSchema, mockAddress(addresses, name), func(server *grpc.Server) {
+			cb(server, h.ctl)
+		}, serverOpts...)
+		require.NoError(t, err, "failed to create gRPC mock handler %q", name)
+	}
+
+	for name, cb := range o.http {
+		h.httpMocks[name], err = NewHTTPMockHandler(cfg.HTTPListenSchema, mockAddress(addresses, name), func(server *http.ServeMux) {
+			cb(server, h.ctl)
 		})
-		require.NoError(t, err, "failed to create HTTP mock handler")
+		require.NoError(t, err, "failed to create HTTP mock handler %q", name)
+
+		if cfg.HTTPMockTLS {
+			h.httpMocks[name].EnableTLS(h.certs.ServerTLSConfig(cfg.MockClientAuth))
+		}
 	}
 
 	return h
 }
 
+func parseMockAddresses(items []string) (map[string]string, error) {
+	addresses := make(map[string]string, len(items))
+	for _, item := range items {
+		name, address, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("mock address %q should be in \"name=address\" format", item)
+		}
+		addresses[name] = address
+	}
+	return addresses, nil
+}
+
+func mockAddress(addresses map[string]string, name string) string {
+	if address, ok := addresses[name]; ok && address != "" {
+		return address
+	}
+	return "127.0.0.1:0"
+}
+
+func newMockCertificates(t *testing.T, cfg *MocksConfig) *MockCertificates {
+	dir := cfg.MockCertsDir
+	if dir == "" {
+		dir = t.TempDir()
+	}
+	certs, err := GenerateMockCertificates(dir, cfg.MockCertHosts...)
+	require.NoError(t, err, "failed to generate mock certificates")
+
+	if cfg.MockClientCAFile != "" {
+		require.NoError(t, certs.AddClientCA(cfg.MockClientCAFile), "failed to load client CA")
+	}
+	return certs
+}
+
+// Certificates returns the generated PKI, nil if TLS is not enabled for any mock server.
+// Pass CAFile (and ClientCertFile/ClientKeyFile for mTLS) to the app to let it trust the mocks.
+func (m *MocksHandler) Certificates() *MockCertificates {
+	return m.certs
+}
+
+// HTTPMock returns the default HTTP mock server, nil if the HTTP callback was not provided.
+func (m *MocksHandler) HTTPMock() *HTTPMockHandler {
+	return m.httpMocks[DefaultHTTPMockName]
+}
+
+// GRPCMock returns the default gRPC mock server, nil if the gRPC callback was not provided.
+func (m *MocksHandler) GRPCMock() *GRPCMockHandler {
+	return m.grpcMocks[DefaultGRPCMockName]
+}
+
+// HTTPServer returns the HTTP mock server by name, nil if there is no such server.
+func (m *MocksHandler) HTTPServer(name string) *HTTPMockHandler {
+	return m.httpMocks[name]
+}
+
+// GRPCServer returns the gRPC mock server by name, nil if there is no such server.
+func (m *MocksHandler) GRPCServer(name string) *GRPCMockHandler {
+	return m.grpcMocks[name]
+}
+
+// Addr returns the resolved "host:port" of the named mock server, empty string if there is no such server.
+func (m *MocksHandler) Addr(name string) string {
+	if h, ok := m.httpMocks[name]; ok {
+		return h.Addr()
+	}
+	if h, ok := m.grpcMocks[name]; ok {
+		return h.Addr()
+	}
+	return ""
+}
+
+// Addrs returns resolved addresses of all mock servers by name.
+func (m *MocksHandler) Addrs() map[string]string {
+	addrs := make(map[string]string, len(m.httpMocks)+len(m.grpcMocks))
+	for name, h := range m.httpMocks {
+		addrs[name] = h.Addr()
+	}
+	for name, h := range m.grpcMocks {
+		addrs[name] = h.Addr()
+	}
+	return addrs
+}
+
 func (m *MocksHandler) Start(t *testing.T) {
-	if m.grpcMockHandler != nil {
+	for _, h := range m.grpcMocks {
 		go func() {
-			if err := m.grpcMockHandler.Start(); err != nil && !errors.Is(err, net.ErrClosed) {
+			if err := h.Start(); err != nil && !errors.Is(err, net.ErrClosed) {
 				t.Error(err)
 			}
 		}()
 	}
-	if m.httpMockHandler != nil {
+	for _, h := range m.httpMocks {
 		go func() {
-			if err := m.httpMockHandler.Start(); err != nil && !errors.Is(err, net.ErrClosed) {
+			if err := h.Start(); err != nil && !errors.Is(err, net.ErrClosed) {
 				t.Error(err)
 			}
 		}()
 	}
 }
 
+// Controller returns the gomock controller mocks are currently registered with.
+func (m *MocksHandler) Controller() *gomock.Controller {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ctl
+}
+
+// bind registers all mocks again with a new controller of t, so expectations are checked by t.
+// Running servers keep their addresses, gRPC clients reconnect.
+func (m *MocksHandler) bind(t *testing.T) {
+	m.register(gomock.NewController(t))
+}
+
+// register registers all mocks again with the controller
+func (m *MocksHandler) register(ctl *gomock.Controller) {
+	m.mu.Lock()
+	m.ctl = ctl
+	m.expectations = nil
+	m.mu.Unlock()
+
+	for name, cb := range m.grpcCBs {
+		m.grpcMocks[name].rebind(func(server *grpc.Server) {
+			cb(server, ctl)
+		})
+	}
+	for name, cb := range m.httpCBs {
+		m.httpMocks[name].rebind(func(server *http.ServeMux) {
+			cb(server, ctl)
+		})
+	}
+}
+
 func (m *MocksHandler) Stop() {
-	m.ctl.Finish()
-	if m.grpcMockHandler != nil {
-		_ = m.grpcMockHandler.Stop() //nolint:errcheck 
+	m.Controller().Finish()
+	for _, h := range m.grpcMocks {
+		_ = h.Stop() //nolint:errcheck
 	}
-	if m.httpMockHandler != nil {
-		_ = m.httpMockHandler.Stop() //nolint:errcheck
+	for _, h := range m.httpMocks {
+		_ = h.Stop() //nolint:errcheck
 	}
 }
@@ -0,0 +1,53 @@
+package goat
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMocksHandlerNamedServers(t *testing.T) {
+	handle := func(body string) HTTPCB {
+		return func(server *http.ServeMux, _ *gomock.Controller) {
+			server.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(body))
+			})
+		}
+	}
+
+	mocks := NewMocksHandler(t, nil, handle("default"),
+		WithHTTPMock("payments", handle("payments")),
+		WithHTTPMock("users", handle("users")),
+	)
+	mocks.Start(t)
+	defer mocks.Stop()
+
+	addrs := mocks.Addrs()
+	require.Len(t, addrs, 3)
+	require.NotEqual(t, addrs["payments"], addrs["users"])
+	require.Empty(t, mocks.Addr("unknown"))
+
+	for _, name := range []string{DefaultHTTPMockName, "payments", "users"} {
+		rsp, err := http.Get("http://" + mocks.Addr(name) + "/")
+		require.NoError(t, err)
+		body, err := io.ReadAll(rsp.Body)
+		require.NoError(t, err)
+		require.NoError(t, rsp.Body.Close())
+		if name == DefaultHTTPMockName {
+			name = "default"
+		}
+		require.Equal(t, name, string(body))
+	}
+}
+
+func TestParseMockAddresses(t *testing.T) {
+	addresses, err := parseMockAddresses([]string{"payments=127.0.0.1:9000", " users=:9001"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"payments": "127.0.0.1:9000", "users": ":9001"}, addresses)
+
+	_, err = parseMockAddresses([]string{"127.0.0.1:9000"})
+	require.Error(t, err)
+}
@@ -0,0 +1,240 @@
+package goat
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const timestampFullName = "google.protobuf.Timestamp"
+
+var protocmpMessageType = reflect.TypeOf(protocmp.Message{})
+
+type (
+	// ProtoEqMatcher implements gomock.Matcher and gomock.GotFormatter for protobuf messages
+	// with configurable comparison, the failure message contains a field-level diff.
+	ProtoEqMatcher struct {
+		msg         proto.Message
+		predicates  map[string]func(v interface{}) bool
+		ignored     map[string]bool
+		opts        cmp.Options
+		description []string
+	}
+
+	// ProtoMatcherOption configures ProtoEqMatcher.
+	ProtoMatcherOption func(m *ProtoEqMatcher)
+)
+
+// ProtoEq returns a matcher comparing protobuf messages with the expected one.
+//
+// Example:
+//
+//	client.EXPECT().CreateOrder(gomock.Any(), gtt.ProtoEq(want,
+//		gtt.IgnoreProtoFields("id", "created_at"),
+//		gtt.UnorderedProtoRepeated(),
+//	))
+func ProtoEq(msg proto.Message, opts ...ProtoMatcherOption) *ProtoEqMatcher {
+	m := &ProtoEqMatcher{
+		msg:        msg,
+		predicates: make(map[string]func(v interface{}) bool),
+		ignored:    make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// IgnoreProtoFields skips fields by dotted path of field names, e.g. "id", "order.created_at".
+// Repeated fields are transparent in paths: "items.id" ignores id of every item.
+func IgnoreProtoFields(paths ...string) ProtoMatcherOption {
+	return func(m *ProtoEqMatcher) {
+		for _, p := range paths {
+			m.ignored[p] = true
+		}
+		m.description = append(m.description, "ignoring "+strings.Join(paths, ", "))
+	}
+}
+
+// PartialProto compares only fields set in the expected message.
+func PartialProto() ProtoMatcherOption {
+	return func(m *ProtoEqMatcher) {
+		m.opts = append(m.opts, cmp.FilterPath(func(p cmp.Path) bool {
+			mi, ok := p.Last().(cmp.MapIndex)
+			if !ok || p.Index(-2).Type() != protocmpMessageType {
+				return false
+			}
+			want, _ := mi.Values()
+			return !want.IsValid()
+		}, cmp.Ignore()))
+		m.description = append(m.description, "partial")
+	}
+}
+
+// UnorderedProtoRepeated compares all repeated fields as multisets.
+func UnorderedProtoRepeated() ProtoMatcherOption {
+	return func(m *ProtoEqMatcher) {
+		m.opts = append(m.opts,
+			cmpopts.SortSlices(func(x, y bool) bool { return !x && y }),
+			cmpopts.SortSlices(func(x, y int32) bool { return x < y }),
+			cmpopts.SortSlices(func(x, y int64) bool { return x < y }),
+			cmpopts.SortSlices(func(x, y uint32) bool { return x < y }),
+			cmpopts.SortSlices(func(x, y uint64) bool { return x < y }),
+			cmpopts.SortSlices(func(x, y float32) bool { return x < y }),
+			cmpopts.SortSlices(func(x, y float64) bool { return x < y }),
+			cmpopts.SortSlices(func(x, y string) bool { return x < y }),
+			cmpopts.SortSlices(func(x, y []byte) bool { return bytes.Compare(x, y) < 0 }),
+			cmpopts.SortSlices(func(x, y protocmp.Enum) bool { return x.Number() < y.Number() }),
+			cmpopts.SortSlices(func(x, y protocmp.Message) bool { return x.String() < y.String() }),
+		)
+		m.description = append(m.description, "unordered repeated fields")
+	}
+}
+
+// ApproxProtoFloats treats float and double fields as equal if they differ by no more than margin.
+func ApproxProtoFloats(margin float64) ProtoMatcherOption {
+	return func(m *ProtoEqMatcher) {
+		m.opts = append(m.opts, cmpopts.EquateApprox(0, margin))
+		m.description = append(m.description, fmt.Sprintf("floats ±%v", margin))
+	}
+}
+
+// ApproxProtoTimestamps treats google.protobuf.Timestamp values as equal if they differ by no more than margin.
+func ApproxProtoTimestamps(margin time.Duration) ProtoMatcherOption {
+	return func(m *ProtoEqMatcher) {
+		m.opts = append(m.opts, cmp.FilterValues(func(x, y protocmp.Message) bool {
+			return x.Descriptor().FullName() == timestampFullName && y.Descriptor().FullName() == timestampFullName
+		}, cmp.Comparer(func(x, y protocmp.Message) bool {
+			diff := protoTimestamp(x).Sub(protoTimestamp(y))
+			return time.Duration(math.Abs(float64(diff))) <= margin
+		})))
+		m.description = append(m.description, fmt.Sprintf("timestamps ±%s", margin))
+	}
+}
+
+// ProtoFieldMatches checks the field of the actual message with the predicate instead of comparing it with the expected value.
+// The predicate gets a Go value of the field: scalar, proto.Message, protoreflect.List or protoreflect.Map.
+// The path must go through singular message fields, e.g. "order.id".
+func ProtoFieldMatches(path string, pred func(v interface{}) bool) ProtoMatcherOption {
+	return func(m *ProtoEqMatcher) {
+		m.predicates[path] = pred
+		m.ignored[path] = true
+		m.description = append(m.description, path+" matches predicate")
+	}
+}
+
+func (m *ProtoEqMatcher) options() cmp.Options {
+	opts := cmp.Options{protocmp.Transform()}
+	if len(m.ignored) != 0 {
+		opts = append(opts, cmp.FilterPath(func(p cmp.Path) bool {
+			return m.ignored[protoFieldPath(p)]
+		}, cmp.Ignore()))
+	}
+	return append(opts, m.opts...)
+}
+
+// Matches implements gomock.Matcher.
+func (m *ProtoEqMatcher) Matches(x interface{}) bool {
+	msg, ok := x.(proto.Message)
+	if !ok {
+		return false
+	}
+	if len(m.failedPredicates(msg)) != 0 {
+		return false
+	}
+	return cmp.Equal(m.msg, msg, m.options())
+}
+
+func (m *ProtoEqMatcher) failedPredicates(msg proto.Message) []string {
+	var failed []string
+	for path, pred := range m.predicates {
+		v, ok := protoFieldValue(msg, path)
+		if !ok || !pred(v) {
+			failed = append(failed, path)
+		}
+	}
+	return failed
+}
+
+// String implements gomock.Matcher.
+func (m *ProtoEqMatcher) String() string {
+	if len(m.description) == 0 {
+		return fmt.Sprintf("is %s", m.msg)
+	}
+	return fmt.Sprintf("is %s (%s)", m.msg, strings.Join(m.description, "; "))
+}
+
+// Got implements gomock.GotFormatter, it prints the diff between expected and actual messages.
+func (m *ProtoEqMatcher) Got(got interface{}) string {
+	msg, ok := got.(proto.Message)
+	if !ok {
+		return fmt.Sprintf("%v (%T is not a proto message)", got, got)
+	}
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("%s\n", msg))
+	if diff := cmp.Diff(m.msg, msg, m.options()); diff != "" {
+		buf.WriteString("diff (-want +got):\n")
+		buf.WriteString(diff)
+	}
+	for _, path := range m.failedPredicates(msg) {
+		buf.WriteString(fmt.Sprintf("field %s does not match predicate\n", path))
+	}
+	return buf.String()
+}
+
+// Got implements gomock.GotFormatter, it prints the diff between expected and actual messages.
+func (r ProtoMatcher) Got(got interface{}) string {
+	return ProtoEq(r.Msg).Got(got)
+}
+
+// protoFieldPath returns dotted message field names of the path, skipping list indexes.
+func protoFieldPath(p cmp.Path) string {
+	var names []string
+	for i := 1; i < len(p); i++ {
+		mi, ok := p[i].(cmp.MapIndex)
+		if !ok || p[i-1].Type() != protocmpMessageType {
+			continue
+		}
+		names = append(names, mi.Key().String())
+	}
+	return strings.Join(names, ".")
+}
+
+func protoFieldValue(msg proto.Message, path string) (interface{}, bool) {
+	m := msg.ProtoReflect()
+	parts := strings.Split(path, ".")
+	for i, name := range parts {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, false
+		}
+		v := m.Get(fd)
+		isMessage := fd.Message() != nil && !fd.IsList() && !fd.IsMap()
+		if i == len(parts)-1 {
+			if isMessage {
+				return v.Message().Interface(), true
+			}
+			return v.Interface(), true
+		}
+		if !isMessage || !m.Has(fd) {
+			return nil, false
+		}
+		m = v.Message()
+	}
+	return nil, false
+}
+
+func protoTimestamp(m protocmp.Message) time.Time {
+	seconds, _ := m["seconds"].(int64) //nolint:errcheck // unset field is zero
+	nanos, _ := m["nanos"].(int32)     //nolint:errcheck // unset field is zero
+	return time.Unix(seconds, int64(nanos))
+}
@@ -0,0 +1,111 @@
+package goat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestProtoEq(t *testing.T) {
+	got := &apipb.Api{
+		Name:    "orders",
+		Version: "v2",
+		Methods: []*apipb.Method{{Name: "Get"}, {Name: "Create"}},
+		SourceContext: &sourcecontextpb.SourceContext{
+			FileName: "orders-123.proto",
+		},
+	}
+
+	tests := []struct {
+		matcher *ProtoEqMatcher
+		name    string
+		want    bool
+	}{
+		{
+			name:    "exact",
+			matcher: ProtoEq(got),
+			want:    true,
+		},
+		{
+			name:    "different",
+			matcher: ProtoEq(&apipb.Api{Name: "orders"}),
+			want:    false,
+		},
+		{
+			name: "ignore fields",
+			matcher: ProtoEq(&apipb.Api{
+				Name:    "orders",
+				Methods: []*apipb.Method{{Name: "Get"}, {Name: "Create"}},
+				SourceContext: &sourcecontextpb.SourceContext{
+					FileName: "orders-456.proto",
+				},
+			}, IgnoreProtoFields("version", "source_context.file_name")),
+			want: true,
+		},
+		{
+			name:    "partial",
+			matcher: ProtoEq(&apipb.Api{Name: "orders", Version: "v2"}, PartialProto()),
+			want:    true,
+		},
+		{
+			name:    "partial mismatch",
+			matcher: ProtoEq(&apipb.Api{Name: "orders", Version: "v1"}, PartialProto()),
+			want:    false,
+		},
+		{
+			name: "unordered repeated",
+			matcher: ProtoEq(&apipb.Api{
+				Name:    "orders",
+				Methods: []*apipb.Method{{Name: "Create"}, {Name: "Get"}},
+			}, PartialProto(), UnorderedProtoRepeated()),
+			want: true,
+		},
+		{
+			name: "ordered repeated",
+			matcher: ProtoEq(&apipb.Api{
+				Name:    "orders",
+				Methods: []*apipb.Method{{Name: "Create"}, {Name: "Get"}},
+			}, PartialProto()),
+			want: false,
+		},
+		{
+			name: "field predicate",
+			matcher: ProtoEq(&apipb.Api{Name: "orders"}, PartialProto(),
+				ProtoFieldMatches("source_context.file_name", func(v interface{}) bool {
+					return strings.HasPrefix(v.(string), "orders-")
+				})),
+			want: true,
+		},
+		{
+			name: "field predicate mismatch",
+			matcher: ProtoEq(&apipb.Api{Name: "orders"}, PartialProto(),
+				ProtoFieldMatches("source_context.file_name", func(v interface{}) bool {
+					return v.(string) == ""
+				})),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.matcher.Matches(got), tt.matcher.Got(got))
+		})
+	}
+}
+
+func TestProtoEqApprox(t *testing.T) {
+	now := time.Now()
+	require.True(t, ProtoEq(timestamppb.New(now), ApproxProtoTimestamps(time.Second)).Matches(timestamppb.New(now.Add(500*time.Millisecond))))
+	require.False(t, ProtoEq(timestamppb.New(now), ApproxProtoTimestamps(time.Second)).Matches(timestamppb.New(now.Add(2*time.Second))))
+}
+
+func TestProtoEqGot(t *testing.T) {
+	m := ProtoEq(&apipb.Api{Name: "orders"}, PartialProto())
+	require.Contains(t, m.String(), "partial")
+	require.Contains(t, m.Got(&apipb.Api{Name: "users"}), "diff (-want +got)")
+	require.Contains(t, m.Got("not a message"), "is not a proto message")
+}
@@ -0,0 +1,41 @@
+package goat
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mainEnv is the env of CallMain, it is used by Require
+var mainEnv atomic.Pointer[Env]
+
+// Require starts services needed by the test unless they are running, with their dependencies.
+// With a lazy manager (services.ManagerConfig.Lazy or GOAT_LAZY_SERVICES=true) only services of the tests
+// that run are started, e.g. go test -run TestRedisOnly starts redis only:
+//
+//	func TestRedisOnly(t *testing.T) {
+//		gtt.Require(t, "redis")
+//		...
+//	}
+//
+// It uses the env passed to CallMain.
+func Require(t testing.TB, names ...string) {
+	t.Helper()
+	env := mainEnv.Load()
+	if env == nil {
+		t.Fatal("goat.Require needs the env of CallMain, use env.Require otherwise")
+		return
+	}
+	env.Require(t, names...)
+}
+
+// Require starts services needed by the test unless they are running, with their dependencies.
+// The start is limited by EnvConfig.StartTimeout.
+func (e *Env) Require(t testing.TB, names ...string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), e.Conf.startTimeout())
+	defer cancel()
+	require.NoError(t, e.manager.Ensure(ctx, names...), "failed to start required services %v", names)
+}
@@ -0,0 +1,465 @@
+package goat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	StepGiven = "given"
+	StepWhen  = "when"
+	StepThen  = "then"
+
+	// DefaultStepTimeout is the timeout of steps without their own one
+	DefaultStepTimeout = time.Minute
+
+	// stepGracePeriod is how long a step is waited for after its timeout, to report its own error
+	stepGracePeriod = 100 * time.Millisecond
+)
+
+var errStepFailed = errors.New("test failed")
+
+type (
+	// StepFunc is a scenario step, it should return once ctx is done.
+	// Steps run in their own goroutine, sc.T fails the step with t.FailNow and require.
+	// A step still running after its timeout fails and is abandoned, its goroutine keeps running and its sc.T is muted.
+	StepFunc func(ctx context.Context, sc *ScenarioContext) error
+
+	// ArtifactFunc returns an artifact captured when a step fails, e.g. app logs or a DB dump
+	ArtifactFunc func(ctx context.Context, sc *ScenarioContext) ([]byte, error)
+
+	// ScenarioContext is shared by steps of a scenario
+	ScenarioContext struct {
+		T testing.TB
+		// Flow is nil if the scenario has no flow
+		Flow *Flow
+		// Vars passes values between steps
+		Vars map[string]interface{}
+	}
+
+	// StepOption configures a single step
+	StepOption func(s *scenarioStep)
+
+	// ScenarioOption configures a scenario
+	ScenarioOption func(s *ScenarioRunner)
+
+	// StepResult is the outcome of a step, Scenario.Run logs them as a summary
+	StepResult struct {
+		Err      error
+		Kind     string
+		Name     string
+		Duration time.Duration
+		Skipped  bool
+	}
+
+	// ScenarioRunner runs steps built by Scenario
+	ScenarioRunner struct {
+		sc           *ScenarioContext
+		artifacts    map[string]ArtifactFunc
+		artifactsDir string
+		steps        []*scenarioStep
+		results      []StepResult
+		timeout      time.Duration
+	}
+
+	scenarioStep struct {
+		fn      StepFunc
+		kind    string
+		name    string
+		timeout time.Duration
+	}
+)
+
+// Scenario starts a step based test, steps run in the order they are added:
+//
+//	gtt.Scenario(t, gtt.WithScenarioFlow(flow)).
+//		Given("order exists", createOrder).
+//		When("order is paid", payOrder, gtt.StepTimeout(5*time.Second)).
+//		Then("billing is charged", func(ctx context.Context, sc *gtt.ScenarioContext) error {
+//			return sc.Flow.Mocks().WaitForExpectations(ctx)
+//		}).
+//		Run()
+//
+// When a step fails the scenario captures artifacts to GOAT_ARTIFACTS_DIR, or to the test log if it is not set,
+// and skips the remaining steps.
+func Scenario(t testing.TB, opts ...ScenarioOption) *ScenarioRunner {
+	s := &ScenarioRunner{
+		sc: &ScenarioContext{
+			T:    t,
+			Vars: make(map[string]interface{}),
+		},
+		artifacts:    make(map[string]ArtifactFunc),
+		artifactsDir: os.Getenv("GOAT_ARTIFACTS_DIR"),
+		timeout:      DefaultStepTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithScenarioFlow makes the flow available to steps, its mock journals and pending expectations are artifacts
+func WithScenarioFlow(flow *Flow) ScenarioOption {
+	return func(s *ScenarioRunner) {
+		s.sc.Flow = flow
+	}
+}
+
+// WithDefaultStepTimeout sets the timeout of steps without StepTimeout, DefaultStepTimeout by default
+func WithDefaultStepTimeout(timeout time.Duration) ScenarioOption {
+	return func(s *ScenarioRunner) {
+		s.timeout = timeout
+	}
+}
+
+// WithArtifactsDir sets the directory failure artifacts are written to, overriding GOAT_ARTIFACTS_DIR
+func WithArtifactsDir(dir string) ScenarioOption {
+	return func(s *ScenarioRunner) {
+		s.artifactsDir = dir
+	}
+}
+
+// WithArtifact adds an artifact captured when a step fails
+func WithArtifact(name string, fn ArtifactFunc) ScenarioOption {
+	return func(s *ScenarioRunner) {
+		s.artifacts[name] = fn
+	}
+}
+
+// StepTimeout sets the timeout of the step
+func StepTimeout(timeout time.Duration) StepOption {
+	return func(s *scenarioStep) {
+		s.timeout = timeout
+	}
+}
+
+// Given adds a step preparing the state, e.g. mock expectations or DB rows
+func (s *ScenarioRunner) Given(name string, fn StepFunc, opts ...StepOption) *ScenarioRunner {
+	return s.Step(StepGiven, name, fn, opts...)
+}
+
+// When adds a step calling the app
+func (s *ScenarioRunner) When(name string, fn StepFunc, opts ...StepOption) *ScenarioRunner {
+	return s.Step(StepWhen, name, fn, opts...)
+}
+
+// Then adds a step checking the outcome
+func (s *ScenarioRunner) Then(name string, fn StepFunc, opts ...StepOption) *ScenarioRunner {
+	return s.Step(StepThen, name, fn, opts...)
+}
+
+// Step adds a step of the kind, Given, When and Then are shortcuts for it
+func (s *ScenarioRunner) Step(kind, name string, fn StepFunc, opts ...StepOption) *ScenarioRunner {
+	step := &scenarioStep{
+		fn:   fn,
+		kind: kind,
+		name: name,
+	}
+	for _, opt := range opts {
+		opt(step)
+	}
+	s.steps = append(s.steps, step)
+	return s
+}
+
+// Results returns results of steps run so far
+func (s *ScenarioRunner) Results() []StepResult {
+	return append([]StepResult(nil), s.results...)
+}
+
+// Run runs the steps and fails the test on the first failed step.
+// Steps may also fail the test with require, artifacts are captured in that case too.
+func (s *ScenarioRunner) Run() {
+	t := s.sc.T
+	t.Helper()
+	s.results = s.results[:0]
+
+	var (
+		failed  *scenarioStep
+		started time.Time
+	)
+	failedBefore := t.Failed()
+	defer func() {
+		t.Helper()
+		s.skipRemaining()
+		s.logSummary()
+	}()
+
+	for _, step := range s.steps {
+		started = time.Now()
+		err := s.runStep(step)
+		s.results = append(s.results, StepResult{Kind: step.kind, Name: step.name, Duration: time.Since(started), Err: err})
+		if err == nil && !failedBefore && t.Failed() {
+			// a step reported an error with t.Error
+			err = errStepFailed
+			s.results[len(s.results)-1].Err = err
+		}
+		if err != nil {
+			failed = step
+			break
+		}
+	}
+
+	if failed != nil {
+		s.captureArtifacts(failed)
+		t.Fatalf("%s %q failed: %v", failed.kind, failed.name, s.results[len(s.results)-1].Err)
+	}
+}
+
+// runStep runs the step in its own goroutine, so the timeout is enforced for steps ignoring ctx.
+// t.FailNow and panics of the step fail the step, the scenario is reported on the test goroutine.
+func (s *ScenarioRunner) runStep(step *scenarioStep) error {
+	timeout := step.timeout
+	if timeout == 0 {
+		timeout = s.timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tb := &stepTB{TB: s.sc.T}
+	sc := *s.sc
+	sc.T = tb
+
+	result := make(chan error, 1)
+	go func() {
+		// stays errStepFailed if the step calls t.FailNow
+		err := errStepFailed
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("step panicked: %v", r)
+			}
+			result <- err
+		}()
+		err = step.fn(ctx, &sc)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		select {
+		case err = <-result:
+		case <-time.After(stepGracePeriod):
+			tb.abandon()
+			return fmt.Errorf("step is still running after timeout %s: %w", timeout, context.DeadlineExceeded)
+		}
+	}
+	if err == nil && tb.stepFailed() {
+		err = errStepFailed
+	}
+	if err == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("step timed out after %s", timeout)
+	}
+	return err
+}
+
+// stepTB is the testing.TB of a step goroutine, FailNow stops the step instead of the test.
+// Calls are ignored once the step is abandoned, the test may be completed by then.
+type stepTB struct {
+	testing.TB
+	mu        sync.Mutex
+	failed    bool
+	abandoned bool
+}
+
+func (s *stepTB) abandon() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abandoned = true
+}
+
+func (s *stepTB) stepFailed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// report calls fn with the test unless the step is abandoned, fail marks the step failed
+func (s *stepTB) report(fail bool, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fail {
+		s.failed = true
+	}
+	if !s.abandoned {
+		fn()
+	}
+}
+
+func (s *stepTB) Fail() {
+	s.report(true, s.TB.Fail)
+}
+
+func (s *stepTB) FailNow() {
+	s.Fail()
+	runtime.Goexit()
+}
+
+func (s *stepTB) Failed() bool {
+	return s.stepFailed() || s.TB.Failed()
+}
+
+func (s *stepTB) Error(args ...interface{}) {
+	s.report(true, func() { s.TB.Error(args...) })
+}
+
+func (s *stepTB) Errorf(format string, args ...interface{}) {
+	s.report(true, func() { s.TB.Errorf(format, args...) })
+}
+
+func (s *stepTB) Fatal(args ...interface{}) {
+	s.Error(args...)
+	runtime.Goexit()
+}
+
+func (s *stepTB) Fatalf(format string, args ...interface{}) {
+	s.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (s *stepTB) Log(args ...interface{}) {
+	s.report(false, func() { s.TB.Log(args...) })
+}
+
+func (s *stepTB) Logf(format string, args ...interface{}) {
+	s.report(false, func() { s.TB.Logf(format, args...) })
+}
+
+// Skip fails the step, a scenario can not be skipped from a step goroutine
+func (s *stepTB) Skip(args ...interface{}) {
+	s.Errorf("step can not be skipped: %s", fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+// Skipf fails the step, a scenario can not be skipped from a step goroutine
+func (s *stepTB) Skipf(format string, args ...interface{}) {
+	s.Skip(fmt.Sprintf(format, args...))
+}
+
+// SkipNow fails the step, a scenario can not be skipped from a step goroutine
+func (s *stepTB) SkipNow() {
+	s.Skip()
+}
+
+func (s *ScenarioRunner) skipRemaining() {
+	for _, step := range s.steps[len(s.results):] {
+		s.results = append(s.results, StepResult{Kind: step.kind, Name: step.name, Skipped: true})
+	}
+}
+
+func (s *ScenarioRunner) logSummary() {
+	s.sc.T.Helper()
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	var total time.Duration
+	for _, r := range s.results {
+		state := "ok"
+		switch {
+		case r.Skipped:
+			state = "skipped"
+		case r.Err != nil:
+			state = "FAILED"
+		}
+		total += r.Duration
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Kind, r.Name, r.Duration.Round(time.Millisecond), state)
+	}
+	_, _ = fmt.Fprintf(w, "total\t\t%s\n", total.Round(time.Millisecond))
+	_ = w.Flush()
+	s.sc.T.Log("scenario steps:\n" + buf.String())
+}
+
+// captureArtifacts writes artifacts of the failed step to the artifacts dir or to the test log
+func (s *ScenarioRunner) captureArtifacts(step *scenarioStep) {
+	t := s.sc.T
+	t.Helper()
+	artifacts := s.collectArtifacts()
+	if len(artifacts) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if s.artifactsDir == "" {
+		for _, name := range names {
+			t.Logf("artifact %s of %s %q:\n%s", name, step.kind, step.name, artifacts[name])
+		}
+		return
+	}
+
+	dir := filepath.Join(s.artifactsDir, artifactName(t.Name()), artifactName(step.kind+"-"+step.name))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Logf("failed to create artifacts dir: %v", err)
+		return
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, artifactName(name)+".txt")
+		if err := os.WriteFile(path, artifacts[name], 0o600); err != nil {
+			t.Logf("failed to write artifact %s: %v", name, err)
+		}
+	}
+	t.Logf("artifacts of %s %q are written to %s", step.kind, step.name, dir)
+}
+
+func (s *ScenarioRunner) collectArtifacts() map[string][]byte {
+	artifacts := make(map[string][]byte)
+	if flow := s.sc.Flow; flow != nil {
+		if !flow.mocks.Controller().Satisfied() {
+			var buf strings.Builder
+			buf.WriteString("expectations of the mocks are not satisfied\n")
+			for _, pending := range flow.mocks.PendingExpectations() {
+				buf.WriteString(pending + "\n")
+			}
+			artifacts["expectations"] = []byte(buf.String())
+		}
+		for name, h := range flow.mocks.grpcMocks {
+			var buf strings.Builder
+			for _, call := range h.Calls() {
+				buf.WriteString(call.String())
+			}
+			if buf.Len() != 0 {
+				artifacts["grpc-"+name] = []byte(buf.String())
+			}
+		}
+		for name, h := range flow.mocks.httpMocks {
+			var buf strings.Builder
+			for _, req := range h.Requests() {
+				buf.WriteString(req.String())
+			}
+			if buf.Len() != 0 {
+				artifacts["http-"+name] = []byte(buf.String())
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultStepTimeout)
+	defer cancel()
+	for name, fn := range s.artifacts {
+		data, err := fn(ctx, s.sc)
+		if err != nil {
+			data = []byte(fmt.Sprintf("failed to capture artifact: %v\n", err))
+		}
+		artifacts[name] = data
+	}
+	return artifacts
+}
+
+var unsafeArtifactChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func artifactName(name string) string {
+	return strings.Trim(unsafeArtifactChars.ReplaceAllString(name, "_"), "_")
+}
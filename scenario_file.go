@@ -0,0 +1,194 @@
+package goat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// ScenarioAction implements steps of YAML scenarios, args are the args of the step in the file
+	ScenarioAction func(ctx context.Context, sc *ScenarioContext, args map[string]interface{}) error
+
+	// ScenarioActions holds actions available to YAML scenarios by name
+	ScenarioActions struct {
+		actions map[string]ScenarioAction
+		mu      sync.RWMutex
+	}
+
+	// ScenarioFile is a scenario written by QA in YAML:
+	//
+	//	name: paid order is charged
+	//	timeout: 30s
+	//	given:
+	//	  - action: load_fixtures
+	//	    args: {files: [fixtures/orders.yaml]}
+	//	when:
+	//	  - name: pay order
+	//	    action: http_post
+	//	    timeout: 5s
+	//	    args: {path: /v1/orders/1/pay}
+	//	then:
+	//	  - action: wait_mocks
+	ScenarioFile struct {
+		Name string `yaml:"name"`
+		// Timeout is the default timeout of steps
+		Timeout time.Duration      `yaml:"timeout"`
+		Given   []ScenarioFileStep `yaml:"given"`
+		When    []ScenarioFileStep `yaml:"when"`
+		Then    []ScenarioFileStep `yaml:"then"`
+	}
+
+	// ScenarioFileStep is a step of ScenarioFile, the name defaults to the action
+	ScenarioFileStep struct {
+		Args    map[string]interface{} `yaml:"args"`
+		Name    string                 `yaml:"name"`
+		Action  string                 `yaml:"action"`
+		Timeout time.Duration          `yaml:"timeout"`
+	}
+
+	scenarioFileGroup struct {
+		kind  string
+		steps []ScenarioFileStep
+	}
+)
+
+// NewScenarioActions creates an empty actions registry.
+func NewScenarioActions() *ScenarioActions {
+	return &ScenarioActions{
+		actions: make(map[string]ScenarioAction),
+	}
+}
+
+// Register registers an action.
+// Returns an error if the action is already registered.
+func (a *ScenarioActions) Register(name string, action ScenarioAction) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.actions[name]; exists {
+		return fmt.Errorf("scenario action %q is already registered", name)
+	}
+	a.actions[name] = action
+	return nil
+}
+
+// MustRegister registers an action and panics if it fails.
+func (a *ScenarioActions) MustRegister(name string, action ScenarioAction) {
+	if err := a.Register(name, action); err != nil {
+		panic(err)
+	}
+}
+
+// Get retrieves an action by name.
+func (a *ScenarioActions) Get(name string) (ScenarioAction, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	action, ok := a.actions[name]
+	return action, ok
+}
+
+// List returns names of all registered actions.
+func (a *ScenarioActions) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.actions))
+	for name := range a.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadScenarioFile reads a YAML scenario, the name defaults to the file name
+func LoadScenarioFile(path string) (*ScenarioFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseScenarioFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if f.Name == "" {
+		f.Name = filepath.Base(path)
+	}
+	return f, nil
+}
+
+// ParseScenarioFile parses a YAML scenario, unknown keys are errors to catch typos
+func ParseScenarioFile(data []byte) (*ScenarioFile, error) {
+	var f ScenarioFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	for _, group := range f.groups() {
+		for i, step := range group.steps {
+			if step.Action == "" {
+				return nil, fmt.Errorf("%s step %d has no action", group.kind, i+1)
+			}
+		}
+	}
+	return &f, nil
+}
+
+func (f *ScenarioFile) groups() []scenarioFileGroup {
+	return []scenarioFileGroup{{StepGiven, f.Given}, {StepWhen, f.When}, {StepThen, f.Then}}
+}
+
+// Scenario builds a scenario of the file with the actions, options are applied after the file timeout.
+// Unknown actions are reported at once, before any step runs.
+func (f *ScenarioFile) Scenario(t testing.TB, actions *ScenarioActions, opts ...ScenarioOption) (*ScenarioRunner, error) {
+	if f.Timeout != 0 {
+		opts = append([]ScenarioOption{WithDefaultStepTimeout(f.Timeout)}, opts...)
+	}
+	s := Scenario(t, opts...)
+	for _, group := range f.groups() {
+		for _, step := range group.steps {
+			action, ok := actions.Get(step.Action)
+			if !ok {
+				return nil, fmt.Errorf("unknown scenario action %q, registered: %v", step.Action, actions.List())
+			}
+			name := step.Name
+			if name == "" {
+				name = step.Action
+			}
+			args := step.Args
+			s.Step(group.kind, name, func(ctx context.Context, sc *ScenarioContext) error {
+				return action(ctx, sc, args)
+			}, StepTimeout(step.Timeout))
+		}
+	}
+	return s, nil
+}
+
+// RunScenarioFiles runs scenarios of files matching the pattern, e.g. "scenarios/*.yaml", one subtest per file
+func RunScenarioFiles(t *testing.T, pattern string, actions *ScenarioActions, opts ...ScenarioOption) {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	require.NoError(t, err, "invalid scenarios pattern")
+	require.NotEmpty(t, paths, "no scenario files match %q", pattern)
+
+	for _, path := range paths {
+		f, err := LoadScenarioFile(path)
+		require.NoError(t, err)
+		t.Run(f.Name, func(t *testing.T) {
+			s, err := f.Scenario(t, actions, opts...)
+			require.NoError(t, err, path)
+			s.Run()
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package goat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTB records failures of a scenario instead of failing the test
+type recordingTB struct {
+	testing.TB
+	fatal  string
+	logs   []string
+	failed bool
+}
+
+func (r *recordingTB) Helper()      {}
+func (r *recordingTB) Name() string { return "TestScenario/checkout" }
+func (r *recordingTB) Failed() bool { return r.failed }
+func (r *recordingTB) Log(args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprint(args...))
+}
+func (r *recordingTB) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+func (r *recordingTB) Fail() { r.failed = true }
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+func (r *recordingTB) Fatalf(format string, args ...interface{}) {
+	r.failed = true
+	r.fatal = fmt.Sprintf(format, args...)
+}
+
+func TestScenario(t *testing.T) {
+	var order []string
+	step := func(name string) StepFunc {
+		return func(_ context.Context, sc *ScenarioContext) error {
+			order = append(order, name)
+			sc.Vars[name] = true
+			return nil
+		}
+	}
+
+	s := Scenario(t).
+		Given("order exists", step("given")).
+		When("order is paid", step("when")).
+		Then("order is charged", func(ctx context.Context, sc *ScenarioContext) error {
+			require.Equal(sc.T, true, sc.Vars["when"])
+			return step("then")(ctx, sc)
+		})
+	s.Run()
+
+	require.Equal(t, []string{"given", "when", "then"}, order)
+	results := s.Results()
+	require.Len(t, results, 3)
+	require.Equal(t, StepThen, results[2].Kind)
+	require.Equal(t, "order is charged", results[2].Name)
+	require.NoError(t, results[2].Err)
+}
+
+func TestScenarioFailedStep(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	dir := t.TempDir()
+
+	s := Scenario(tb, WithArtifactsDir(dir), WithArtifact("db", func(context.Context, *ScenarioContext) ([]byte, error) {
+		return []byte("orders: 1"), nil
+	})).
+		Given("order exists", func(context.Context, *ScenarioContext) error { return nil }).
+		When("order is paid", func(ctx context.Context, _ *ScenarioContext) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, StepTimeout(10*time.Millisecond)).
+		Then("order is charged", func(context.Context, *ScenarioContext) error {
+			t.Fatal("steps after the failed one must be skipped")
+			return nil
+		})
+	s.Run()
+
+	require.True(t, tb.failed)
+	require.Equal(t, `when "order is paid" failed: context deadline exceeded`, tb.fatal)
+
+	results := s.Results()
+	require.ErrorIs(t, results[1].Err, context.DeadlineExceeded)
+	require.GreaterOrEqual(t, results[1].Duration, 10*time.Millisecond)
+	require.True(t, results[2].Skipped)
+
+	data, err := os.ReadFile(filepath.Join(dir, "TestScenario_checkout", "when-order_is_paid", "db.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "orders: 1", string(data))
+
+	summary := tb.logs[len(tb.logs)-1]
+	require.Contains(t, summary, "FAILED")
+	require.Contains(t, summary, "skipped")
+}
+
+func TestScenarioStepIgnoringTimeout(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	release := make(chan struct{})
+	defer close(release)
+
+	s := Scenario(tb, WithArtifact("db", func(context.Context, *ScenarioContext) ([]byte, error) {
+		return []byte("orders: 1"), nil
+	})).
+		When("order is paid", func(context.Context, *ScenarioContext) error {
+			<-release
+			return nil
+		}, StepTimeout(10*time.Millisecond)).
+		Then("order is charged", func(context.Context, *ScenarioContext) error { return nil })
+	s.Run()
+
+	require.True(t, tb.failed)
+	require.Contains(t, tb.fatal, `when "order is paid" failed: step is still running after timeout 10ms`)
+	results := s.Results()
+	require.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+	require.True(t, results[1].Skipped)
+	require.Contains(t, tb.logs, "artifact db of when \"order is paid\":\norders: 1")
+}
+
+func TestScenarioStepFailNow(t *testing.T) {
+	tb := &recordingTB{TB: t}
+
+	s := Scenario(tb).
+		When("order is paid", func(_ context.Context, sc *ScenarioContext) error {
+			require.Equal(sc.T, "paid", "new")
+			return nil
+		}).
+		Then("order is charged", func(context.Context, *ScenarioContext) error { return nil })
+	s.Run()
+
+	require.True(t, tb.failed)
+	require.Equal(t, `when "order is paid" failed: test failed`, tb.fatal)
+	require.Contains(t, tb.logs[0], "Not equal")
+	require.True(t, s.Results()[1].Skipped)
+}
+
+func TestScenarioAbandonedStep(t *testing.T) {
+	release := make(chan struct{})
+	reported := make(chan interface{}, 1)
+
+	t.Run("checkout", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		Scenario(tb).
+			When("order is paid", func(_ context.Context, sc *ScenarioContext) error {
+				defer func() { reported <- recover() }()
+				<-release
+				// the test is completed, the call must be ignored
+				sc.T.Error("late failure")
+				return nil
+			}, StepTimeout(10*time.Millisecond)).
+			Run()
+		require.True(t, tb.failed)
+	})
+
+	close(release)
+	require.Nil(t, <-reported)
+}
+
+func TestScenarioFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pay.yaml"), []byte(`
+name: paid order is charged
+timeout: 5s
+given:
+  - action: set
+    args: {key: order, value: 42}
+when:
+  - name: pay order
+    action: copy
+    timeout: 1s
+    args: {from: order, to: paid}
+then:
+  - action: check
+    args: {key: paid, value: 42}
+`), 0o600))
+
+	actions := NewScenarioActions()
+	actions.MustRegister("set", func(_ context.Context, sc *ScenarioContext, args map[string]interface{}) error {
+		sc.Vars[args["key"].(string)] = args["value"]
+		return nil
+	})
+	actions.MustRegister("copy", func(_ context.Context, sc *ScenarioContext, args map[string]interface{}) error {
+		sc.Vars[args["to"].(string)] = sc.Vars[args["from"].(string)]
+		return nil
+	})
+	actions.MustRegister("check", func(_ context.Context, sc *ScenarioContext, args map[string]interface{}) error {
+		if got := sc.Vars[args["key"].(string)]; got != args["value"] {
+			return errors.New("unexpected value")
+		}
+		return nil
+	})
+	require.Error(t, actions.Register("set", nil))
+
+	RunScenarioFiles(t, filepath.Join(dir, "*.yaml"), actions)
+
+	f, err := ParseScenarioFile([]byte("when:\n  - action: unknown\n"))
+	require.NoError(t, err)
+	_, err = f.Scenario(t, actions)
+	require.ErrorContains(t, err, `unknown scenario action "unknown", registered: [check copy set]`)
+
+	_, err = ParseScenarioFile([]byte("then:\n  - name: no action\n"))
+	require.ErrorContains(t, err, "then step 1 has no action")
+
+	_, err = ParseScenarioFile([]byte("given:\n  - action: set\n    arg: {}\n"))
+	require.True(t, err != nil && strings.Contains(err.Error(), "field arg not found"), err)
+}
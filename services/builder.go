@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"time"
 
 	testcontainers "github.com/testcontainers/testcontainers-go"
 )
@@ -38,6 +39,18 @@ func (b *Builder) WithStopOnError(stop bool) *Builder {
 	return b
 }
 
+// WithLazy sets whether services start on first use instead of Start.
+func (b *Builder) WithLazy(lazy bool) *Builder {
+	b.config.Lazy = lazy
+	return b
+}
+
+// WithLazyStartTimeout limits starts of services by Get and GetTyped in lazy mode.
+func (b *Builder) WithLazyStartTimeout(timeout time.Duration) *Builder {
+	b.config.LazyStartTimeout = timeout
+	return b
+}
+
 // WithService adds a service to the builder.
 // The service must be registered in DefaultRegistry, otherwise it panics.
 //
@@ -1,9 +1,14 @@
 package services
 
 import (
+	"time"
+
 	testcontainers "github.com/testcontainers/testcontainers-go"
 )
 
+// DefaultLazyStartTimeout limits starts of services by Get and GetTyped in lazy mode
+const DefaultLazyStartTimeout = 10 * time.Minute
+
 // Config holds the configuration for a single service.
 type Config struct {
 	// HealthCheck is an optional health check function to verify service readiness
@@ -34,14 +39,26 @@ type ManagerConfig struct {
 	// StopOnError determines whether to stop all services if one fails to start.
 	// Default: true
 	StopOnError bool
+
+	// Lazy makes Start a no-op, services and their dependencies start on the first
+	// Get/GetTyped or Ensure call instead.
+	// Default: GOAT_LAZY_SERVICES environment variable
+	Lazy bool
+
+	// LazyStartTimeout limits the start of a service with its dependencies by Get/GetTyped in lazy mode,
+	// so a stuck image pull fails the call instead of blocking the test.
+	// Default: DefaultLazyStartTimeout if zero
+	LazyStartTimeout time.Duration
 }
 
 // DefaultManagerConfig returns a ManagerConfig with sensible defaults.
 func DefaultManagerConfig() ManagerConfig {
 	return ManagerConfig{
-		MaxParallel: 10,
-		Logger:      NewDefaultLogger(),
-		StopOnError: true,
+		MaxParallel:      10,
+		Logger:           NewDefaultLogger(),
+		StopOnError:      true,
+		Lazy:             lazyFromEnv(),
+		LazyStartTimeout: DefaultLazyStartTimeout,
 	}
 }
 
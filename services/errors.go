@@ -1,6 +1,9 @@
 package services
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // ErrServiceNotFound is returned when a requested service is not found in the registry.
 type ErrServiceNotFound struct {
@@ -99,3 +102,12 @@ type ErrTypeMismatch struct {
 func (e *ErrTypeMismatch) Error() string {
 	return fmt.Sprintf("service %q type mismatch: cannot cast to requested type", e.ServiceName)
 }
+
+// ErrDependencyCycle is returned when service dependencies form a cycle.
+type ErrDependencyCycle struct {
+	Path []string
+}
+
+func (e *ErrDependencyCycle) Error() string {
+	return fmt.Sprintf("service dependency cycle: %s", strings.Join(e.Path, " -> "))
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+)
+
+func lazyFromEnv() bool {
+	lazy, _ := strconv.ParseBool(os.Getenv("GOAT_LAZY_SERVICES")) //nolint:errcheck // invalid value is false
+	return lazy
+}
+
+// Ensure starts the services and their dependencies transitively if they are not running yet.
+// Dependencies start first, a service being started by another goroutine is awaited.
+// It works in both modes, e.g. to start services of a focused test run in lazy mode.
+func (m *Manager) Ensure(ctx context.Context, names ...string) error {
+	order, err := m.resolveDependencies(names)
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		if err := m.ensureService(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) ensureService(ctx context.Context, name string) error {
+	for {
+		m.mu.Lock()
+		if _, ok := m.running[name]; ok {
+			m.mu.Unlock()
+			return nil
+		}
+		if wait, ok := m.starting[name]; ok {
+			m.mu.Unlock()
+			select {
+			case <-wait:
+				// started or failed, check again
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		m.starting[name] = done
+		m.mu.Unlock()
+
+		cfg := m.config[name]
+		err := m.startService(ctx, name, &cfg)
+
+		m.mu.Lock()
+		delete(m.starting, name)
+		m.mu.Unlock()
+		close(done)
+		return err
+	}
+}
+
+// resolveDependencies returns the services with their dependencies, every dependency goes before its dependents
+func (m *Manager) resolveDependencies(names []string) ([]string, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	order := make([]string, 0, len(names))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return &ErrDependencyCycle{Path: append(path, name)}
+		}
+		cfg, ok := m.config[name]
+		if !ok {
+			return &ErrServiceNotFound{ServiceName: name}
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range cfg.Dependencies {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -19,6 +19,7 @@ type ServiceEnv struct {
 // Manager manages the lifecycle of multiple service containers.
 type Manager struct {
 	running  map[string]*ServiceEnv
+	starting map[string]chan struct{}
 	config   ServicesMap
 	registry *Registry
 	mconfig  ManagerConfig
@@ -36,6 +37,7 @@ func NewManager(services ServicesMap, config ManagerConfig) *Manager {
 		mconfig:  config,
 		registry: DefaultRegistry,
 		running:  make(map[string]*ServiceEnv),
+		starting: make(map[string]chan struct{}),
 	}
 }
 
@@ -50,11 +52,18 @@ func NewManagerWithRegistry(services ServicesMap, config ManagerConfig, registry
 		mconfig:  config,
 		registry: registry,
 		running:  make(map[string]*ServiceEnv),
+		starting: make(map[string]chan struct{}),
 	}
 }
 
 // Start starts all enabled services.
+// In lazy mode nothing is started, services start on first use.
 func (m *Manager) Start(ctx context.Context) error {
+	if m.mconfig.Lazy {
+		m.mconfig.Logger.Info("lazy mode, services start on first use", "total", len(m.config))
+		return nil
+	}
+
 	m.mconfig.Logger.Info("starting services", "total", len(m.config))
 
 	// Group services by priority
@@ -115,7 +124,20 @@ func (m *Manager) Stop(ctx context.Context) error {
 }
 
 // Get retrieves a running service by name.
+// In lazy mode the service and its dependencies are started first, limited by ManagerConfig.LazyStartTimeout.
 func (m *Manager) Get(name string) (*ServiceEnv, error) {
+	if m.mconfig.Lazy {
+		timeout := m.mconfig.LazyStartTimeout
+		if timeout <= 0 {
+			timeout = DefaultLazyStartTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := m.Ensure(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -256,9 +278,10 @@ func (m *Manager) RestartAll(ctx context.Context) error {
 		configs[env.Name] = env.Config
 	}
 
-	// Create temporary manager with same config
+	// Create temporary manager with same config, the services were running so they start at once
 	tempManager := NewManager(configs, m.mconfig)
 	tempManager.registry = m.registry
+	tempManager.mconfig.Lazy = false
 
 	// Start all services
 	if err := tempManager.Start(ctx); err != nil {
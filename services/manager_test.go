@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -256,3 +258,94 @@ func TestErrors(t *testing.T) {
 		assert.Contains(t, err.Error(), "dep")
 	})
 }
+
+// terminateContainer records Terminate calls, other methods are not used by Manager
+type terminateContainer struct {
+	testcontainers.Container
+	terminated bool
+}
+
+func (c *terminateContainer) Terminate(context.Context, ...testcontainers.TerminateOption) error {
+	c.terminated = true
+	return nil
+}
+
+func TestLazyManager(t *testing.T) {
+	var started []string
+	var mu sync.Mutex
+	runner := func(name string) *MockRunner {
+		return &MockRunner{name: name, runFunc: func(context.Context, ...testcontainers.ContainerCustomizer) (testcontainers.Container, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			started = append(started, name)
+			return &terminateContainer{}, nil
+		}}
+	}
+	registry := NewRegistry()
+	for _, name := range []string{"postgres", "kafka", "zookeeper", "redis"} {
+		registry.MustRegister(name, runner(name))
+	}
+	servicesMap := ServicesMap{"postgres": {}, "kafka": {}, "zookeeper": {}, "redis": {}}.
+		WithDependencies("kafka", "zookeeper", "postgres")
+
+	config := DefaultManagerConfig()
+	config.Logger = NewNoopLogger()
+	config.Lazy = true
+	manager := NewManagerWithRegistry(servicesMap, config, registry)
+
+	require.NoError(t, manager.Start(context.Background()))
+	assert.Empty(t, manager.ListRunning())
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := manager.Get("kafka")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"kafka", "postgres", "zookeeper"}, manager.ListRunning())
+	assert.Equal(t, []string{"zookeeper", "postgres", "kafka"}, started)
+
+	require.NoError(t, manager.Ensure(context.Background(), "redis"))
+	assert.True(t, manager.IsRunning("redis"))
+
+	var notFound *ErrServiceNotFound
+	_, err := manager.Get("mysql")
+	require.ErrorAs(t, err, &notFound)
+
+	require.NoError(t, manager.Stop(context.Background()))
+	assert.Empty(t, manager.ListRunning())
+}
+
+func TestLazyManagerStartTimeout(t *testing.T) {
+	registry := NewRegistry()
+	registry.MustRegister("postgres", &MockRunner{name: "postgres", runFunc: func(ctx context.Context, _ ...testcontainers.ContainerCustomizer) (testcontainers.Container, error) {
+		// a stuck image pull
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}})
+	manager := NewManagerWithRegistry(ServicesMap{"postgres": {}}, ManagerConfig{
+		Logger:           NewNoopLogger(),
+		Lazy:             true,
+		LazyStartTimeout: 50 * time.Millisecond,
+	}, registry)
+
+	_, err := manager.Get("postgres")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, manager.IsRunning("postgres"))
+}
+
+func TestEnsureDependencyCycle(t *testing.T) {
+	servicesMap := ServicesMap{"a": {}, "b": {}, "c": {}}.
+		WithDependencies("a", "b").
+		WithDependencies("b", "c").
+		WithDependencies("c", "a")
+	manager := NewManagerWithRegistry(servicesMap, ManagerConfig{Logger: NewNoopLogger()}, NewRegistry())
+
+	var cycle *ErrDependencyCycle
+	require.ErrorAs(t, manager.Ensure(context.Background(), "a"), &cycle)
+	assert.Equal(t, "service dependency cycle: a -> b -> c -> a", cycle.Error())
+}
@@ -0,0 +1,70 @@
+package goat
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+// SharedFlow is a flow started once and reused by subtests, e.g. to start a slow app once per package.
+// Every subtest run by Run gets a fresh gomock controller, so unmet expectations fail the subtest
+// that set them and do not leak into the next one.
+type SharedFlow struct {
+	flow *Flow
+	t    *testing.T
+	// ctl is the controller of t, mocks are registered with it again after every subtest
+	ctl     *gomock.Controller
+	running atomic.Bool
+}
+
+// NewSharedFlow creates and starts a flow stopped by t.Cleanup, subtests are run with Run:
+//
+//	func TestOrders(t *testing.T) {
+//		var billing *mocks.MockBillingServer
+//		flow := gtt.NewSharedFlow(t, env, appFactory, nil, func(server *grpc.Server, ctl *gomock.Controller) {
+//			billing = mocks.NewMockBillingServer(ctl)
+//			pb.RegisterBillingServer(server, billing)
+//		})
+//		flow.Run(t, "charge", func(t *testing.T, m *gtt.MocksHandler) {
+//			billing.EXPECT().Charge(gomock.Any(), gomock.Any()).Return(&pb.ChargeResponse{}, nil)
+//			...
+//		})
+//	}
+//
+// Callbacks are called again for every subtest, so variables they assign refer to mocks of the running subtest.
+func NewSharedFlow(t *testing.T, env *Env, app AppFactory, hcb HTTPCB, gCb GrpcCB, opts ...MocksOption) *SharedFlow {
+	t.Helper()
+	f := NewFlowWithApp(t, env, app, hcb, gCb, opts...)
+	f.Start(t, nil, nil)
+	return &SharedFlow{flow: f, t: t, ctl: f.mocks.Controller()}
+}
+
+// Flow returns the underlying flow.
+func (s *SharedFlow) Flow() *Flow {
+	return s.flow
+}
+
+// Mocks returns the mock servers of the flow.
+func (s *SharedFlow) Mocks() *MocksHandler {
+	return s.flow.mocks
+}
+
+// Run runs fn as a subtest of t with mocks registered with a controller of the subtest.
+// Subtests share the app, so they must not call t.Parallel.
+func (s *SharedFlow) Run(t *testing.T, name string, fn func(t *testing.T, mocks *MocksHandler)) bool {
+	t.Helper()
+	return t.Run(name, func(t *testing.T) {
+		if !s.running.CompareAndSwap(false, true) {
+			t.Fatal("subtests of a shared flow can't run in parallel")
+		}
+		defer s.running.Store(false)
+
+		s.flow.mocks.bind(t)
+		// registered after the controller, so mocks are rebound to the parent test before Finish of the subtest
+		t.Cleanup(func() {
+			s.flow.mocks.register(s.ctl)
+		})
+		fn(t, s.flow.mocks)
+	})
+}
@@ -0,0 +1,214 @@
+package goat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// SSEEvent is a Server-Sent Event.
+	SSEEvent struct {
+		ID    string
+		Event string
+		Data  string
+		// Retry is sent as reconnection time if not zero
+		Retry time.Duration
+	}
+
+	// SSERecord is a transcript record of a sent event.
+	SSERecord struct {
+		At    time.Time
+		Event SSEEvent
+	}
+
+	// SSEMock is an http.Handler serving a scripted stream of Server-Sent Events.
+	// Register it on the mock ServeMux, the script is run for every accepted connection:
+	//
+	//	sse := gtt.NewSSEMock().
+	//		Send(gtt.SSEEvent{Event: "hello", Data: "{}"}).
+	//		WaitTrigger("update").
+	//		SendAfter(time.Second, gtt.SSEEvent{Event: "update", Data: `{"v":2}`}).
+	//		Close()
+	//	server.Handle("/events", sse)
+	SSEMock struct {
+		script     *streamScript[*sseConn]
+		conn       *sseConn
+		transcript []SSERecord
+		requests   []*http.Request
+		m          sync.Mutex
+	}
+
+	sseConn struct {
+		w       http.ResponseWriter
+		flusher http.Flusher
+		mock    *SSEMock
+		closed  chan struct{}
+		once    sync.Once
+		wm      sync.Mutex
+	}
+)
+
+// NewSSEMock creates an empty SSE script.
+func NewSSEMock() *SSEMock {
+	return &SSEMock{
+		script: newStreamScript[*sseConn](),
+	}
+}
+
+// WithStepTimeout limits every script step, 10 seconds by default.
+func (s *SSEMock) WithStepTimeout(d time.Duration) *SSEMock {
+	s.script.timeout = d
+	return s
+}
+
+// Send sends the event.
+func (s *SSEMock) Send(ev SSEEvent) *SSEMock {
+	s.script.add("send "+ev.Event, func(_ context.Context, c *sseConn) error {
+		return c.write(ev)
+	})
+	return s
+}
+
+// SendAfter sends the event after the delay.
+func (s *SSEMock) SendAfter(d time.Duration, ev SSEEvent) *SSEMock {
+	s.script.add(fmt.Sprintf("send %s after %s", ev.Event, d), func(ctx context.Context, c *sseConn) error {
+		if !sleepContext(ctx, d) {
+			return ctx.Err()
+		}
+		return c.write(ev)
+	})
+	return s
+}
+
+// WaitTrigger pauses the script until the test calls Trigger with the name.
+func (s *SSEMock) WaitTrigger(name string) *SSEMock {
+	s.script.add("wait trigger "+name, func(ctx context.Context, _ *sseConn) error {
+		return s.script.waitTrigger(ctx, name)
+	})
+	return s
+}
+
+// Close ends the stream.
+func (s *SSEMock) Close() *SSEMock {
+	s.script.add("close", func(_ context.Context, c *sseConn) error {
+		c.close()
+		return nil
+	})
+	return s
+}
+
+// Trigger releases a WaitTrigger step.
+func (s *SSEMock) Trigger(name string) {
+	s.script.fire(name)
+}
+
+// Push sends the event to the last accepted connection outside of the script.
+func (s *SSEMock) Push(ev SSEEvent) error {
+	s.m.Lock()
+	c := s.conn
+	s.m.Unlock()
+	if c == nil {
+		return fmt.Errorf("no sse connection")
+	}
+	return c.write(ev)
+}
+
+// Transcript returns all events sent to all connections in order.
+func (s *SSEMock) Transcript() []SSERecord {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]SSERecord(nil), s.transcript...)
+}
+
+// Requests returns subscription requests, e.g. to check Last-Event-ID header on reconnect.
+func (s *SSEMock) Requests() []*http.Request {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// Wait blocks until the script is completed by a connection and returns script errors.
+func (s *SSEMock) Wait(ctx context.Context) error {
+	return s.script.wait(ctx)
+}
+
+func (s *SSEMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	c := &sseConn{
+		w:       w,
+		flusher: flusher,
+		mock:    s,
+		closed:  make(chan struct{}),
+	}
+	s.m.Lock()
+	s.conn = c
+	s.requests = append(s.requests, r.Clone(context.Background()))
+	s.m.Unlock()
+
+	_ = s.script.execute(r.Context(), c) //nolint:errcheck // errors are reported by Wait
+
+	select {
+	case <-c.closed:
+	case <-r.Context().Done():
+	}
+	c.close()
+}
+
+func (c *sseConn) write(ev SSEEvent) error {
+	c.wm.Lock()
+	defer c.wm.Unlock()
+
+	select {
+	case <-c.closed:
+		return fmt.Errorf("sse stream is closed")
+	default:
+	}
+
+	var buf strings.Builder
+	if ev.ID != "" {
+		buf.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString(fmt.Sprintf("retry: %d\n", ev.Retry.Milliseconds()))
+	}
+	for _, line := range strings.Split(ev.Data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+
+	if _, err := c.w.Write([]byte(buf.String())); err != nil {
+		return err
+	}
+	c.flusher.Flush()
+
+	c.mock.m.Lock()
+	c.mock.transcript = append(c.mock.transcript, SSERecord{At: time.Now(), Event: ev})
+	c.mock.m.Unlock()
+	return nil
+}
+
+func (c *sseConn) close() {
+	c.once.Do(func() {
+		c.wm.Lock()
+		close(c.closed)
+		c.wm.Unlock()
+	})
+}
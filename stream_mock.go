@@ -0,0 +1,116 @@
+package goat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultStreamStepTimeout = 10 * time.Second
+
+// streamScript is a sequence of steps shared by WebSocket and SSE mocks.
+// The script is run for every accepted connection, triggers fired by the test release WaitTrigger steps.
+type streamScript[C any] struct {
+	triggers map[string]chan struct{}
+	done     chan struct{}
+	steps    []streamStep[C]
+	errs     []error
+	timeout  time.Duration
+	running  int
+	m        sync.Mutex
+}
+
+type streamStep[C any] struct {
+	run  func(ctx context.Context, conn C) error
+	name string
+}
+
+func newStreamScript[C any]() *streamScript[C] {
+	return &streamScript[C]{
+		triggers: make(map[string]chan struct{}),
+		done:     make(chan struct{}),
+		timeout:  defaultStreamStepTimeout,
+	}
+}
+
+func (s *streamScript[C]) add(name string, run func(ctx context.Context, conn C) error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.steps = append(s.steps, streamStep[C]{name: name, run: run})
+}
+
+func (s *streamScript[C]) trigger(name string) chan struct{} {
+	s.m.Lock()
+	defer s.m.Unlock()
+	ch, ok := s.triggers[name]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		s.triggers[name] = ch
+	}
+	return ch
+}
+
+func (s *streamScript[C]) fire(name string) {
+	select {
+	case s.trigger(name) <- struct{}{}:
+	default:
+	}
+}
+
+func (s *streamScript[C]) waitTrigger(ctx context.Context, name string) error {
+	select {
+	case <-s.trigger(name):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("trigger %q is not fired: %w", name, ctx.Err())
+	}
+}
+
+// execute runs all steps against the connection, every step is limited by the step timeout.
+func (s *streamScript[C]) execute(ctx context.Context, conn C) error {
+	s.m.Lock()
+	steps := append([]streamStep[C](nil), s.steps...)
+	s.running++
+	s.m.Unlock()
+
+	var err error
+	for i, step := range steps {
+		stepCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		stepErr := step.run(stepCtx, conn)
+		cancel()
+		if stepErr != nil {
+			err = fmt.Errorf("step %d %q failed: %w", i+1, step.name, stepErr)
+			break
+		}
+	}
+
+	s.m.Lock()
+	if err != nil {
+		s.errs = append(s.errs, err)
+	}
+	s.running--
+	if s.running == 0 {
+		select {
+		case <-s.done:
+		default:
+			close(s.done)
+		}
+	}
+	s.m.Unlock()
+
+	return err
+}
+
+// wait blocks until a connection completed the script and returns script errors.
+func (s *streamScript[C]) wait(ctx context.Context) error {
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return fmt.Errorf("script is not completed: %w", ctx.Err())
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	return errors.Join(s.errs...)
+}
@@ -0,0 +1,141 @@
+package goat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func startStreamMock(t *testing.T, path string, handler http.Handler) string {
+	t.Helper()
+	h, err := NewHTTPMockHandler("tcp", "127.0.0.1:0", func(server *http.ServeMux) {
+		server.Handle(path, handler)
+	})
+	require.NoError(t, err)
+	go func() {
+		_ = h.Start()
+	}()
+	t.Cleanup(func() {
+		_ = h.Stop()
+	})
+	return h.Addr()
+}
+
+func TestWSMock(t *testing.T) {
+	ws := NewWSMock().
+		ExpectMessage("subscribe", WSMessageContains("subscribe")).
+		SendText("subscribed").
+		WaitTrigger("price").
+		SendText("price:100").
+		Close(websocket.CloseGoingAway, "bye")
+	addr := startStreamMock(t, "/ws", ws)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil) //nolint:bodyclose // upgraded connection
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"op":"subscribe"}`)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "subscribed", string(msg))
+
+	ws.Trigger("price")
+	_, msg, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "price:100", string(msg))
+
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, ws.Wait(ctx))
+
+	transcript := ws.Transcript()
+	require.GreaterOrEqual(t, len(transcript), 4)
+	require.Equal(t, WSInbound, transcript[0].Direction)
+	require.Equal(t, `{"op":"subscribe"}`, string(transcript[0].Data))
+	require.Equal(t, websocket.CloseMessage, transcript[3].Type)
+}
+
+func TestWSMockUnexpectedMessage(t *testing.T) {
+	ws := NewWSMock().ExpectMessage("subscribe", WSMessageContains("subscribe"))
+	addr := startStreamMock(t, "/ws", ws)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil) //nolint:bodyclose // upgraded connection
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.ErrorContains(t, ws.Wait(ctx), "unexpected message: hello")
+}
+
+func TestWSMockBurst(t *testing.T) {
+	const messages = 300
+	ws := NewWSMock().WaitTrigger("read")
+	for i := range messages {
+		ws.ExpectMessage(fmt.Sprint(i), func(msg []byte) bool { return string(msg) == fmt.Sprint(i) })
+	}
+	addr := startStreamMock(t, "/ws", ws)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil) //nolint:bodyclose // upgraded connection
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// messages sent before the script reads them are not dropped
+	for i := range messages {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprint(i))))
+	}
+	require.Eventually(t, func() bool { return len(ws.Transcript()) > 100 }, 5*time.Second, 10*time.Millisecond)
+	ws.Trigger("read")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, ws.Wait(ctx))
+}
+
+func TestSSEMock(t *testing.T) {
+	sse := NewSSEMock().
+		Send(SSEEvent{ID: "1", Event: "hello", Data: "{}"}).
+		WaitTrigger("update").
+		SendAfter(10*time.Millisecond, SSEEvent{Event: "update", Data: "a\nb"}).
+		Close()
+	addr := startStreamMock(t, "/events", sse)
+
+	rsp, err := http.Get("http://" + addr + "/events")
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+	require.Equal(t, "text/event-stream", rsp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(rsp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, readErr := reader.ReadString('\n')
+			require.NoError(t, readErr)
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	require.Equal(t, "id: 1\nevent: hello\ndata: {}\n", readEvent())
+	sse.Trigger("update")
+	require.Equal(t, "event: update\ndata: a\ndata: b\n", readEvent())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sse.Wait(ctx))
+	require.Len(t, sse.Transcript(), 2)
+	require.Len(t, sse.Requests(), 1)
+}
@@ -0,0 +1,282 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	snapshotDir       = "testdata"
+	sampleRowsOnError = 5
+)
+
+var (
+	updateSnapshots = flag.Bool("goat.update-snapshots", false, "rewrite golden files of MatchQuerySnapshot")
+
+	snapshotCalls   = make(map[string]int)
+	snapshotCallsMu sync.Mutex
+)
+
+// Where is a column filter of DB assertions, nil values match NULL
+type Where map[string]interface{}
+
+// DetectDialect guesses the dialect by the package of the sql driver, Postgres is the default
+func DetectDialect(db *sql.DB) Dialect {
+	t := reflect.TypeOf(db.Driver())
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	pkg := strings.ToLower(t.PkgPath())
+	switch {
+	case strings.Contains(pkg, "mysql"):
+		return DialectMySQL
+	case strings.Contains(pkg, "clickhouse"):
+		return DialectClickHouse
+	default:
+		return DialectPostgres
+	}
+}
+
+// AssertRowExists checks that the table has a row matching the filter.
+// On failure sample rows of the table are printed.
+func AssertRowExists(t testing.TB, db *sql.DB, table string, where Where) bool {
+	t.Helper()
+	count, err := countRows(db, table, where)
+	require.NoError(t, err)
+	if count > 0 {
+		return true
+	}
+	t.Errorf("no row in %s where %s\n%s", table, formatWhere(where), sampleRows(db, table))
+	return false
+}
+
+// AssertNoRow checks that the table has no row matching the filter
+func AssertNoRow(t testing.TB, db *sql.DB, table string, where Where) bool {
+	t.Helper()
+	return AssertRowCount(t, db, table, where, 0)
+}
+
+// AssertRowCount checks the number of rows matching the filter, nil filter counts all rows
+func AssertRowCount(t testing.TB, db *sql.DB, table string, where Where, expected int) bool {
+	t.Helper()
+	count, err := countRows(db, table, where)
+	require.NoError(t, err)
+	if count == expected {
+		return true
+	}
+	t.Errorf("%d rows in %s where %s, expected %d\n%s", count, table, formatWhere(where), expected, sampleRows(db, table))
+	return false
+}
+
+// MatchQuerySnapshot compares query results with testdata/<test name>.golden, the query must have a stable order.
+// A missing golden file fails the test, golden files are only written with -goat.update-snapshots flag or GOAT_UPDATE_SNAPSHOTS=true.
+// Several snapshots of one test get _2, _3 suffixes.
+func MatchQuerySnapshot(t testing.TB, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+
+	got, err := querySnapshot(db, query, args...)
+	require.NoError(t, err, "failed to query snapshot")
+
+	path := filepath.Join(snapshotDir, snapshotName(t)+".golden")
+	if shouldUpdateSnapshots() {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o644)) //nolint:gosec // golden files are committed
+		t.Logf("snapshot %s is written", path)
+		return
+	}
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Errorf("golden file %s is missing, rerun with -goat.update-snapshots or GOAT_UPDATE_SNAPSHOTS=true", path)
+		return
+	}
+	require.NoError(t, err)
+
+	if string(want) != got {
+		t.Errorf("query result does not match snapshot %s (-want +got):\n%srun with GOAT_UPDATE_SNAPSHOTS=true to update",
+			path, diffLines(string(want), got))
+	}
+}
+
+func shouldUpdateSnapshots() bool {
+	if *updateSnapshots {
+		return true
+	}
+	update, _ := strconv.ParseBool(os.Getenv("GOAT_UPDATE_SNAPSHOTS")) //nolint:errcheck // invalid value is false
+	return update
+}
+
+func snapshotName(t testing.TB) string {
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+
+	snapshotCallsMu.Lock()
+	snapshotCalls[name]++
+	n := snapshotCalls[name]
+	snapshotCallsMu.Unlock()
+
+	if n == 1 {
+		t.Cleanup(func() {
+			snapshotCallsMu.Lock()
+			delete(snapshotCalls, name)
+			snapshotCallsMu.Unlock()
+		})
+	}
+
+	if n > 1 {
+		name += "_" + strconv.Itoa(n)
+	}
+	return name
+}
+
+// querySnapshot renders the result as lines: a header of columns and one line per row, values are separated by " | "
+func querySnapshot(db *sql.DB, query string, args ...interface{}) (string, error) {
+	rows, err := db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	buf.WriteString(strings.Join(columns, " | "))
+	buf.WriteString("\n")
+
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return "", err
+		}
+		formatted := make([]string, len(values))
+		for i, v := range values {
+			formatted[i] = formatValue(v)
+		}
+		buf.WriteString(strings.Join(formatted, " | "))
+		buf.WriteString("\n")
+	}
+	return buf.String(), rows.Err()
+}
+
+// diffLines returns a line diff based on the longest common subsequence, unchanged lines are prefixed with two spaces
+func diffLines(want, got string) string {
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var buf strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			buf.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			buf.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			buf.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return buf.String()
+}
+
+func countRows(db *sql.DB, table string, where Where) (int, error) {
+	clause, args := whereClause(DetectDialect(db), where)
+	var count int
+	err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM "+table+clause, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rows in %s: %w", table, err)
+	}
+	return count, nil
+}
+
+func whereClause(dialect Dialect, where Where) (string, []interface{}) {
+	if len(where) == 0 {
+		return "", nil
+	}
+	columns := make([]string, 0, len(where))
+	for column := range where {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	conditions := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		if where[column] == nil {
+			conditions = append(conditions, column+" IS NULL")
+			continue
+		}
+		args = append(args, where[column])
+		conditions = append(conditions, column+" = "+dialect.Placeholder(len(args)))
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func formatWhere(where Where) string {
+	if len(where) == 0 {
+		return "(all rows)"
+	}
+	clause, args := whereClause(DialectMySQL, where)
+	for _, arg := range args {
+		clause = strings.Replace(clause, "?", formatValue(arg), 1)
+	}
+	return strings.TrimPrefix(clause, " WHERE ")
+}
+
+// sampleRows returns a few rows of the table to explain a failed assertion
+func sampleRows(db *sql.DB, table string) string {
+	snapshot, err := querySnapshot(db, fmt.Sprintf("SELECT * FROM %s LIMIT %d", table, sampleRowsOnError))
+	if err != nil {
+		return fmt.Sprintf("failed to read rows of %s: %s", table, err)
+	}
+	return fmt.Sprintf("first rows of %s:\n%s", table, snapshot)
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(val)
+	}
+}
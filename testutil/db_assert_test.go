@@ -0,0 +1,76 @@
+package testutil
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertRows(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.addResult("SELECT COUNT(*) FROM orders WHERE status = $1 AND user_id IS NULL", []string{"count"}, []driver.Value{int64(1)})
+	fake.addResult("SELECT COUNT(*) FROM orders WHERE status = $1 AND user_id = $2", []string{"count"}, []driver.Value{int64(0)})
+	fake.addResult("SELECT COUNT(*) FROM orders", []string{"count"}, []driver.Value{int64(2)})
+	fake.addResult("SELECT * FROM orders LIMIT 5", []string{"id", "status"},
+		[]driver.Value{int64(1), []byte("paid")}, []driver.Value{int64(2), nil})
+
+	require.True(t, AssertRowExists(t, db, "orders", Where{"status": "paid", "user_id": nil}))
+	require.True(t, AssertNoRow(t, db, "orders", Where{"status": "paid", "user_id": 7}))
+	require.True(t, AssertRowCount(t, db, "orders", nil, 2))
+
+	rec := &messageTB{TB: t}
+	require.False(t, AssertRowExists(rec, db, "orders", Where{"status": "paid", "user_id": 7}))
+	require.Contains(t, rec.message, "no row in orders where status = paid AND user_id = 7")
+	require.Contains(t, rec.message, "id | status\n1 | paid\n2 | NULL\n")
+
+	require.False(t, AssertRowCount(rec, db, "orders", nil, 3))
+	require.Contains(t, rec.message, "2 rows in orders where (all rows), expected 3")
+}
+
+func TestMatchQuerySnapshot(t *testing.T) {
+	t.Chdir(t.TempDir())
+	db, fake := newFakeDB(t)
+	fake.addResult("SELECT id, status, paid_at FROM orders", []string{"id", "status", "paid_at"},
+		[]driver.Value{int64(1), []byte("paid"), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)})
+
+	// a missing golden file fails the test
+	rec := &messageTB{TB: t}
+	MatchQuerySnapshot(rec, db, "SELECT id, status, paid_at FROM orders ORDER BY id")
+	require.Contains(t, rec.message, "golden file testdata/TestMatchQuerySnapshot.golden is missing")
+	require.NoFileExists(t, filepath.Join("testdata", "TestMatchQuerySnapshot.golden"))
+
+	require.NoError(t, os.MkdirAll("testdata", 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("testdata", "TestMatchQuerySnapshot_2.golden"),
+		[]byte("id | status | paid_at\n1 | paid | 2024-01-02T03:04:05Z\n"), 0o600))
+	MatchQuerySnapshot(t, db, "SELECT id, status, paid_at FROM orders ORDER BY id")
+
+	require.NoError(t, os.WriteFile(filepath.Join("testdata", "TestMatchQuerySnapshot_3.golden"),
+		[]byte("id | status | paid_at\n1 | new | NULL\n"), 0o600))
+	MatchQuerySnapshot(rec, db, "SELECT id, status, paid_at FROM orders ORDER BY id")
+	require.Contains(t, rec.message, "does not match snapshot")
+	require.Contains(t, rec.message, "  id | status | paid_at\n- 1 | new | NULL\n+ 1 | paid | 2024-01-02T03:04:05Z\n")
+
+	require.NoError(t, os.WriteFile(filepath.Join("testdata", "TestMatchQuerySnapshot_4.golden"), []byte("stale"), 0o600))
+	t.Setenv("GOAT_UPDATE_SNAPSHOTS", "true")
+	MatchQuerySnapshot(t, db, "SELECT id, status, paid_at FROM orders ORDER BY id")
+	golden, err := os.ReadFile(filepath.Join("testdata", "TestMatchQuerySnapshot_4.golden"))
+	require.NoError(t, err)
+	require.Equal(t, "id | status | paid_at\n1 | paid | 2024-01-02T03:04:05Z\n", string(golden))
+}
+
+// messageTB keeps the last failure message instead of failing the test
+type messageTB struct {
+	testing.TB
+	message string
+}
+
+func (m *messageTB) Errorf(format string, args ...interface{}) {
+	m.message = fmt.Sprintf(format, args...)
+}
+
+func (m *messageTB) Helper() {}
@@ -0,0 +1,205 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB is a tiny in-memory database for tests: it keeps the migrations version row,
+// tracks CREATE TABLE / DROP TABLE statements and returns rows registered by query prefix.
+type fakeDB struct {
+	tables  map[string]bool
+	results map[string]fakeResult
+	version []driver.Value
+	execs   []string
+	args    [][]driver.Value
+	m       sync.Mutex
+}
+
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+var (
+	fakeDBs   = make(map[string]*fakeDB)
+	fakeDBsMu sync.Mutex
+
+	createTableRe = regexp.MustCompile(`(?i)CREATE TABLE (\w+)`)
+	dropTableRe   = regexp.MustCompile(`(?i)DROP TABLE (\w+)`)
+)
+
+func init() {
+	sql.Register("goat-testutil-fake", fakeDriver{})
+}
+
+func newFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{
+		tables:  make(map[string]bool),
+		results: make(map[string]fakeResult),
+	}
+	fakeDBsMu.Lock()
+	fakeDBs[t.Name()] = f
+	fakeDBsMu.Unlock()
+
+	db, err := sql.Open("goat-testutil-fake", t.Name())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db, f
+}
+
+// addResult registers rows returned for queries starting with the prefix, the longest matching prefix is used
+func (f *fakeDB) addResult(prefix string, columns []string, rows ...[]driver.Value) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.results[prefix] = fakeResult{columns: columns, rows: rows}
+}
+
+func (f *fakeDB) statements() []string {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return append([]string(nil), f.execs...)
+}
+
+func (f *fakeDB) execArgs() [][]driver.Value {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return append([][]driver.Value(nil), f.args...)
+}
+
+func (f *fakeDB) exec(query string, args []driver.Value) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		return nil
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		f.version = nil
+		return nil
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		f.version = args
+		return nil
+	}
+
+	f.execs = append(f.execs, query)
+	f.args = append(f.args, args)
+	if strings.Contains(query, "FAIL") {
+		return errors.New("statement failed")
+	}
+	for _, m := range createTableRe.FindAllStringSubmatch(query, -1) {
+		if f.tables[m[1]] {
+			return fmt.Errorf("relation %q already exists", m[1])
+		}
+		f.tables[m[1]] = true
+	}
+	for _, m := range dropTableRe.FindAllStringSubmatch(query, -1) {
+		if !f.tables[m[1]] {
+			return fmt.Errorf("table %q does not exist", m[1])
+		}
+		delete(f.tables, m[1])
+	}
+	return nil
+}
+
+func (f *fakeDB) query(query string, args []driver.Value) (driver.Rows, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if strings.HasPrefix(query, "SELECT version, dirty FROM schema_migrations") {
+		rows := &fakeRows{columns: []string{"version", "dirty"}}
+		if f.version != nil {
+			rows.rows = [][]driver.Value{f.version}
+		}
+		return rows, nil
+	}
+	// the longest registered prefix wins
+	var best string
+	for prefix := range f.results {
+		if strings.HasPrefix(query, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return nil, fmt.Errorf("unexpected query %q with %v", query, args)
+	}
+	res := f.results[best]
+	return &fakeRows{columns: res.columns, rows: res.rows}, nil
+}
+
+type (
+	fakeDriver struct{}
+	fakeConn   struct{ db *fakeDB }
+	fakeStmt   struct {
+		db    *fakeDB
+		query string
+	}
+	fakeTx   struct{}
+	fakeRows struct {
+		columns []string
+		rows    [][]driver.Value
+		pos     int
+	}
+)
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	db, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fake db %q", name)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.db.exec(s.query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.db.query(s.query, args)
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
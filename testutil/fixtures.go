@@ -0,0 +1,540 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// FixtureLabelKey is the row key naming the row for references, it is not inserted
+const FixtureLabelKey = "_label"
+
+const (
+	postgresForeignKeysQuery = `SELECT tc.table_name, ccu.table_name
+FROM information_schema.table_constraints tc
+JOIN information_schema.constraint_column_usage ccu
+	ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.table_schema
+WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = current_schema()`
+	mysqlForeignKeysQuery = `SELECT table_name, referenced_table_name
+FROM information_schema.key_column_usage
+WHERE referenced_table_name IS NOT NULL AND table_schema = DATABASE()`
+)
+
+var (
+	// single template actions keep the type of the value: {{now}} is time.Time, {{ref "users.alice.id"}} is the referenced value
+	fixtureNowRe = regexp.MustCompile(`^\{\{\s*now\s*\}\}$`)
+	fixtureRefRe = regexp.MustCompile(`^\{\{\s*ref\s+"([^"]+)"\s*\}\}$`)
+	// fixtureRefTableRe finds tables referenced anywhere in a value
+	fixtureRefTableRe = regexp.MustCompile(`ref\s+"([^".]+)\.`)
+)
+
+type (
+	// Fixtures loads YAML or JSON fixture files into tables and truncates the touched tables on cleanup.
+	//
+	// A fixture file maps table names to rows:
+	//
+	//	users:
+	//	  - _label: alice
+	//	    name: Alice
+	//	    created_at: "{{now}}"
+	//	orders:
+	//	  - user_id: '{{ref "users.alice.id"}}'
+	//	    created_at: '{{nowAdd "-1h"}}'
+	//	    payload: {"source": "web"}   # maps and lists are stored as JSON
+	//
+	// Tables are inserted in foreign key order read from the catalog (Postgres and MySQL) and references between rows.
+	// Values of labeled rows are read back after insert (RETURNING * on Postgres, LastInsertId for id on MySQL),
+	// so references can point to generated columns.
+	Fixtures struct {
+		now     time.Time
+		funcs   template.FuncMap
+		labeled map[string]map[string]interface{}
+		touched map[string]bool
+		dialect Dialect
+	}
+
+	// FixturesOption configures Fixtures
+	FixturesOption func(f *Fixtures)
+
+	fixtureTable struct {
+		name string
+		rows []map[string]interface{}
+	}
+)
+
+var _ TableCleaner = (*Fixtures)(nil)
+
+// WithFixturesDialect sets the SQL dialect, Postgres by default
+func WithFixturesDialect(d Dialect) FixturesOption {
+	return func(f *Fixtures) {
+		f.dialect = d
+	}
+}
+
+// WithFixturesNow fixes the time returned by {{now}}
+func WithFixturesNow(now time.Time) FixturesOption {
+	return func(f *Fixtures) {
+		f.now = now
+	}
+}
+
+// WithFixturesFunc adds a template function available in fixture values
+func WithFixturesFunc(name string, fn interface{}) FixturesOption {
+	return func(f *Fixtures) {
+		f.funcs[name] = fn
+	}
+}
+
+// NewFixtures creates a fixtures loader
+func NewFixtures(opts ...FixturesOption) *Fixtures {
+	f := &Fixtures{
+		labeled: make(map[string]map[string]interface{}),
+		touched: make(map[string]bool),
+		funcs:   make(template.FuncMap),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// LoadForTest loads fixture files and truncates the touched tables when the test finishes
+func (f *Fixtures) LoadForTest(t testing.TB, db *sql.DB, files ...string) {
+	t.Helper()
+	t.Cleanup(func() {
+		require.NoError(t, f.CleanupTables(context.Background(), db), "failed to clean fixture tables")
+	})
+	require.NoError(t, f.Load(context.Background(), db, files...), "failed to load fixtures")
+}
+
+// Load inserts rows of fixture files, .yaml, .yml and .json files are supported
+func (f *Fixtures) Load(ctx context.Context, db *sql.DB, files ...string) error {
+	return f.LoadFS(ctx, db, os.DirFS("."), files...)
+}
+
+// LoadFS inserts rows of fixture files matching the patterns in fsys, e.g. "fixtures/*.yaml"
+func (f *Fixtures) LoadFS(ctx context.Context, db *sql.DB, fsys fs.FS, patterns ...string) error {
+	var tables []*fixtureTable
+	byName := make(map[string]*fixtureTable)
+
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, filepath.ToSlash(filepath.Clean(pattern)))
+		if err != nil {
+			return fmt.Errorf("invalid fixtures pattern %q: %w", pattern, err)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no fixture files match %q", pattern)
+		}
+		for _, name := range names {
+			parsed, err := readFixtureFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			for _, table := range parsed {
+				if existing, ok := byName[table.name]; ok {
+					existing.rows = append(existing.rows, table.rows...)
+					continue
+				}
+				byName[table.name] = table
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	ordered, err := f.order(ctx, db, tables)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range ordered {
+		f.touched[table.name] = true
+		for i, row := range table.rows {
+			if err := f.insert(ctx, db, table.name, row); err != nil {
+				return fmt.Errorf("failed to insert row %d into %s: %w", i+1, table.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Ref returns a value of a labeled row by "table.label.column" path
+func (f *Fixtures) Ref(path string) (interface{}, bool) {
+	idx := strings.LastIndex(path, ".")
+	if idx < 0 {
+		return nil, false
+	}
+	row, ok := f.labeled[path[:idx]]
+	if !ok {
+		return nil, false
+	}
+	v, ok := row[path[idx+1:]]
+	return v, ok
+}
+
+// Touched returns names of tables loaded since the last cleanup
+func (f *Fixtures) Touched() []string {
+	tables := make([]string, 0, len(f.touched))
+	for name := range f.touched {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// CleanupTables implements TableCleaner, it truncates tables touched by fixtures and forgets labeled rows
+func (f *Fixtures) CleanupTables(ctx context.Context, db *sql.DB) error {
+	tables := f.Touched()
+	if len(tables) == 0 {
+		return nil
+	}
+
+	if err := TruncateTables(ctx, db, f.dialect, tables...); err != nil {
+		return err
+	}
+
+	f.touched = make(map[string]bool)
+	f.labeled = make(map[string]map[string]interface{})
+	return nil
+}
+
+// TruncateTables removes all rows of exactly the tables, identities are restarted.
+// Foreign keys between the tables do not block it, other tables are never truncated:
+// Postgres truncates the tables in one statement and fails if a table outside of them references one of them.
+func TruncateTables(ctx context.Context, db *sql.DB, dialect Dialect, tables ...string) error {
+	switch dialect {
+	case DialectPostgres:
+		if _, err := db.ExecContext(ctx, "TRUNCATE TABLE "+strings.Join(tables, ", ")+" RESTART IDENTITY"); err != nil {
+			return fmt.Errorf("failed to truncate tables: %w", err)
+		}
+	case DialectMySQL:
+		// FOREIGN_KEY_CHECKS is a session variable, all statements must use one connection
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to truncate tables: %w", err)
+		}
+		defer conn.Close()
+
+		if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+			return fmt.Errorf("failed to truncate tables: %w", err)
+		}
+		defer conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 1") //nolint:errcheck // connection is returned to the pool anyway
+		for _, table := range tables {
+			if _, err := conn.ExecContext(ctx, "TRUNCATE TABLE "+table); err != nil {
+				return fmt.Errorf("failed to truncate %s: %w", table, err)
+			}
+		}
+	default:
+		for _, table := range tables {
+			if _, err := db.ExecContext(ctx, "TRUNCATE TABLE "+table); err != nil {
+				return fmt.Errorf("failed to truncate %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+func readFixtureFile(fsys fs.FS, name string) ([]*fixtureTable, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures %s: %w", name, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil, fmt.Errorf("unsupported fixtures format %s", name)
+	}
+
+	// JSON is valid YAML, yaml.Node keeps the order of tables
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("fixtures %s must map table names to rows", name)
+	}
+
+	tables := make([]*fixtureTable, 0, len(root.Content)/2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		table := &fixtureTable{name: root.Content[i].Value}
+		if err := root.Content[i+1].Decode(&table.rows); err != nil {
+			return nil, fmt.Errorf("invalid rows of %s in %s: %w", table.name, name, err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// order sorts tables so referenced tables are inserted first, ties keep the order of fixture files
+func (f *Fixtures) order(ctx context.Context, db *sql.DB, tables []*fixtureTable) ([]*fixtureTable, error) {
+	deps, err := f.foreignKeys(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		for _, row := range table.rows {
+			for _, v := range row {
+				s, ok := v.(string)
+				if !ok {
+					continue
+				}
+				for _, m := range fixtureRefTableRe.FindAllStringSubmatch(s, -1) {
+					deps[table.name] = append(deps[table.name], m[1])
+				}
+			}
+		}
+	}
+
+	byName := make(map[string]*fixtureTable, len(tables))
+	for _, table := range tables {
+		byName[table.name] = table
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(tables))
+	ordered := make([]*fixtureTable, 0, len(tables))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("cyclic references between fixture tables: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if _, ok := byName[dep]; !ok || dep == name {
+				continue
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		ordered = append(ordered, byName[name])
+		return nil
+	}
+
+	for _, table := range tables {
+		if err := visit(table.name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// foreignKeys returns referenced tables by table name
+func (f *Fixtures) foreignKeys(ctx context.Context, db *sql.DB) (map[string][]string, error) {
+	deps := make(map[string][]string)
+
+	var query string
+	switch f.dialect {
+	case DialectPostgres:
+		query = postgresForeignKeysQuery
+	case DialectMySQL:
+		query = mysqlForeignKeysQuery
+	default:
+		return deps, nil
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, referenced string
+		if err := rows.Scan(&table, &referenced); err != nil {
+			return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+		}
+		deps[table] = append(deps[table], referenced)
+	}
+	return deps, rows.Err()
+}
+
+func (f *Fixtures) insert(ctx context.Context, db *sql.DB, table string, row map[string]interface{}) error {
+	label, _ := row[FixtureLabelKey].(string) //nolint:errcheck // labels are optional
+
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if column != FixtureLabelKey {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+
+	values := make(map[string]interface{}, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	placeholders := make([]string, 0, len(columns))
+	for i, column := range columns {
+		v, err := f.value(row[column])
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+		values[column] = v
+		args = append(args, v)
+		placeholders = append(placeholders, f.dialect.Placeholder(i+1))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if len(columns) == 0 {
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", table)
+	}
+
+	if label != "" && f.dialect == DialectPostgres {
+		returned, err := queryRow(ctx, db, query+" RETURNING *", args...)
+		if err != nil {
+			return err
+		}
+		for k, v := range returned {
+			values[k] = v
+		}
+	} else {
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if _, ok := values["id"]; !ok && label != "" {
+			if id, err := res.LastInsertId(); err == nil {
+				values["id"] = id
+			}
+		}
+	}
+
+	if label != "" {
+		f.labeled[table+"."+label] = values
+	}
+	return nil
+}
+
+// value renders templates in strings and encodes maps and lists as JSON
+func (f *Fixtures) value(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case string:
+		if !strings.Contains(val, "{{") {
+			return val, nil
+		}
+		if fixtureNowRe.MatchString(val) {
+			return f.currentTime(), nil
+		}
+		if m := fixtureRefRe.FindStringSubmatch(val); m != nil {
+			ref, ok := f.Ref(m[1])
+			if !ok {
+				return nil, fmt.Errorf("unknown reference %q", m[1])
+			}
+			return ref, nil
+		}
+		return f.render(val)
+	default:
+		return v, nil
+	}
+}
+
+func (f *Fixtures) render(text string) (string, error) {
+	funcs := template.FuncMap{
+		"now": func() string {
+			return f.currentTime().Format(time.RFC3339Nano)
+		},
+		"nowAdd": func(d string) (string, error) {
+			dur, err := time.ParseDuration(d)
+			if err != nil {
+				return "", err
+			}
+			return f.currentTime().Add(dur).Format(time.RFC3339Nano), nil
+		},
+		"ref": func(path string) (interface{}, error) {
+			v, ok := f.Ref(path)
+			if !ok {
+				return nil, fmt.Errorf("unknown reference %q", path)
+			}
+			return v, nil
+		},
+	}
+	for name, fn := range f.funcs {
+		funcs[name] = fn
+	}
+
+	tmpl, err := template.New("fixture").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (f *Fixtures) currentTime() time.Time {
+	if !f.now.IsZero() {
+		return f.now
+	}
+	return time.Now()
+}
+
+// queryRow returns the first row of the query as a column map, []byte values are converted to strings
+func queryRow(ctx context.Context, db *sql.DB, query string, args ...interface{}) (map[string]interface{}, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			row[column] = string(b)
+			continue
+		}
+		row[column] = values[i]
+	}
+	return row, rows.Err()
+}
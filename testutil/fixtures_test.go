@@ -0,0 +1,83 @@
+package testutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixturesLoad(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.addResult("SELECT tc.table_name", []string{"table_name", "ref"}, []driver.Value{"orders", "users"})
+	fake.addResult("INSERT INTO users", []string{"id", "name"}, []driver.Value{int64(7), []byte("Alice")})
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"fixtures/orders.yaml": {Data: []byte(`
+orders:
+  - user_id: '{{ref "users.alice.id"}}'
+    created_at: '{{nowAdd "-1h"}}'
+    payload: {"source": "web"}
+    note: 'user {{ref "users.alice.name"}}'
+`)},
+		"fixtures/users.json": {Data: []byte(`{"users": [{"_label": "alice", "name": "Alice", "created_at": "{{now}}"}]}`)},
+	}
+
+	f := NewFixtures(WithFixturesNow(now))
+	require.NoError(t, f.LoadFS(context.Background(), db, fsys, "fixtures/*"))
+
+	id, ok := f.Ref("users.alice.id")
+	require.True(t, ok)
+	require.Equal(t, int64(7), id)
+	require.Equal(t, []string{"orders", "users"}, f.Touched())
+
+	require.Equal(t, []string{
+		"INSERT INTO orders (created_at, note, payload, user_id) VALUES ($1, $2, $3, $4)",
+	}, fake.statements())
+	require.Equal(t, []driver.Value{"2024-01-02T02:04:05Z", "user Alice", `{"source":"web"}`, int64(7)}, fake.execArgs()[0])
+
+	require.NoError(t, f.CleanupTables(context.Background(), db))
+	require.Equal(t, "TRUNCATE TABLE orders, users RESTART IDENTITY", fake.statements()[1])
+	require.Empty(t, f.Touched())
+	_, ok = f.Ref("users.alice.id")
+	require.False(t, ok)
+}
+
+func TestFixturesErrors(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.addResult("SELECT tc.table_name", []string{"table_name", "ref"})
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"unknown_ref.yaml": {Data: []byte(`orders: [{user_id: '{{ref "users.bob.id"}}'}]`)},
+		"cycle.yaml":       {Data: []byte("a: [{b_id: '{{ref \"b.x.id\"}}'}]\nb: [{a_id: '{{ref \"a.x.id\"}}'}]")},
+		"rows.txt":         {Data: []byte("users: []")},
+	}
+
+	require.ErrorContains(t, NewFixtures().LoadFS(ctx, db, fsys, "unknown_ref.yaml"), `unknown reference "users.bob.id"`)
+	require.ErrorContains(t, NewFixtures().LoadFS(ctx, db, fsys, "cycle.yaml"), "cyclic references")
+	require.ErrorContains(t, NewFixtures().LoadFS(ctx, db, fsys, "rows.txt"), "unsupported fixtures format")
+	require.ErrorContains(t, NewFixtures().LoadFS(ctx, db, fsys, "missing/*.yaml"), "no fixture files")
+}
+
+func TestFixturesMySQL(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.addResult("SELECT table_name, referenced_table_name", []string{"table_name", "ref"})
+
+	fsys := fstest.MapFS{"users.yml": {Data: []byte("users:\n  - name: Bob\n    tags: [a, b]\n")}}
+	f := NewFixtures(WithFixturesDialect(DialectMySQL))
+	require.NoError(t, f.LoadFS(context.Background(), db, fsys, "users.yml"))
+	require.NoError(t, f.CleanupTables(context.Background(), db))
+
+	require.Equal(t, []string{
+		"INSERT INTO users (name, tags) VALUES (?, ?)",
+		"SET FOREIGN_KEY_CHECKS = 0",
+		"TRUNCATE TABLE users",
+		"SET FOREIGN_KEY_CHECKS = 1",
+	}, fake.statements())
+	require.Equal(t, []driver.Value{"Bob", `["a","b"]`}, fake.execArgs()[0])
+}
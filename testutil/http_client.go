@@ -0,0 +1,384 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	gtt "github.com/Educentr/goat"
+	"github.com/Educentr/goat/wait"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// DefaultWarmupTimeout is how long the first request is retried while the app is not listening
+	DefaultWarmupTimeout = 10 * time.Second
+
+	exchangeBodyLimit = 2000
+)
+
+type (
+	// AppClient calls the app under test over its transports, base URLs are resolved by
+	// ServiceConfig.TransportPort. Until the app answers, failed dials are retried for the warm-up timeout,
+	// the request was not sent then, so it is safe for any method. Responses of any status are never retried.
+	// Requests and responses are logged if the test fails.
+	AppClient struct {
+		t       testing.TB
+		cfg     ServiceConfig
+		client  *http.Client
+		state   *appClientState
+		headers http.Header
+		host    string
+		warmup  time.Duration
+	}
+
+	// AppClientOption configures AppClient
+	AppClientOption func(c *AppClient)
+
+	// AppResponse is a response of the app with fluent assertions, failed assertions do not stop the test
+	AppResponse struct {
+		t        testing.TB
+		Response *http.Response
+		doc      interface{}
+		docErr   error
+		request  string
+		Body     []byte
+		decoded  bool
+	}
+
+	// appClientState is shared by copies of the client made by WithHeader
+	appClientState struct {
+		exchanges []string
+		mu        sync.Mutex
+		warmedUp  bool
+	}
+)
+
+// NewAppClient creates a client of the app, e.g.
+//
+//	client := testutil.NewAppClient(t, cfg)
+//	client.Post("publicapi", "/v1/orders", map[string]interface{}{"sku": "a"}).
+//		Status(http.StatusCreated).
+//		Header("Content-Type", "application/json").
+//		JSONPath("order.status", "new")
+func NewAppClient(t testing.TB, cfg ServiceConfig, opts ...AppClientOption) *AppClient {
+	c := &AppClient{
+		t:       t,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		state:   &appClientState{},
+		headers: make(http.Header),
+		host:    "127.0.0.1",
+		warmup:  DefaultWarmupTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	t.Cleanup(c.logExchanges)
+	return c
+}
+
+// WithHTTPClient sets the client used for requests, e.g. with TLS settings
+func WithHTTPClient(client *http.Client) AppClientOption {
+	return func(c *AppClient) {
+		c.client = client
+	}
+}
+
+// WithAppHost sets the host of the app, 127.0.0.1 by default
+func WithAppHost(host string) AppClientOption {
+	return func(c *AppClient) {
+		c.host = host
+	}
+}
+
+// WithWarmupTimeout sets how long failed dials of the first request are retried, 0 disables retries
+func WithWarmupTimeout(timeout time.Duration) AppClientOption {
+	return func(c *AppClient) {
+		c.warmup = timeout
+	}
+}
+
+// WithHeader returns a copy of the client sending the header with every request
+func (c *AppClient) WithHeader(key, value string) *AppClient {
+	clone := *c
+	clone.headers = c.headers.Clone()
+	clone.headers.Add(key, value)
+	return &clone
+}
+
+// URL returns the URL of the path on the transport, the test fails if the transport has no port
+func (c *AppClient) URL(transport, path string) string {
+	c.t.Helper()
+	port := c.cfg.TransportPort(transport)
+	require.NotEmpty(c.t, port, "transport %q of %s has no port", transport, c.cfg.ServiceName())
+	return "http://" + net.JoinHostPort(c.host, port) + path
+}
+
+// Get sends a GET request
+func (c *AppClient) Get(transport, path string) *AppResponse {
+	c.t.Helper()
+	return c.Do(http.MethodGet, transport, path, nil)
+}
+
+// Post sends a POST request with the JSON body
+func (c *AppClient) Post(transport, path string, body interface{}) *AppResponse {
+	c.t.Helper()
+	return c.Do(http.MethodPost, transport, path, body)
+}
+
+// Put sends a PUT request with the JSON body
+func (c *AppClient) Put(transport, path string, body interface{}) *AppResponse {
+	c.t.Helper()
+	return c.Do(http.MethodPut, transport, path, body)
+}
+
+// Patch sends a PATCH request with the JSON body
+func (c *AppClient) Patch(transport, path string, body interface{}) *AppResponse {
+	c.t.Helper()
+	return c.Do(http.MethodPatch, transport, path, body)
+}
+
+// Delete sends a DELETE request
+func (c *AppClient) Delete(transport, path string) *AppResponse {
+	c.t.Helper()
+	return c.Do(http.MethodDelete, transport, path, nil)
+}
+
+// Do sends a request, the body is sent as is if it is []byte or string and marshaled to JSON otherwise.
+// The test fails at once if the request can't be sent.
+func (c *AppClient) Do(method, transport, path string, body interface{}) *AppResponse {
+	c.t.Helper()
+	data, contentType, err := encodeBody(body)
+	require.NoError(c.t, err, "failed to encode body of %s %s", method, path)
+	url := c.URL(transport, path)
+
+	var rsp *AppResponse
+	send := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+		if err != nil {
+			return wait.Permanent(err)
+		}
+		req.Header = c.headers.Clone()
+		if contentType != "" && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rsp, err = c.send(req, data)
+		return err
+	}
+
+	if c.warmingUp() {
+		err = wait.For(context.Background(), wait.Func(method+" "+url, func(ctx context.Context) error {
+			err := send(ctx)
+			if err != nil && !isDialError(err) {
+				// the request may have reached the app, it is not repeated
+				return wait.Permanent(err)
+			}
+			return err
+		}), wait.WithTimeout(c.warmup))
+	} else {
+		err = send(context.Background())
+	}
+	require.NoError(c.t, err, "%s %s failed", method, url)
+
+	c.state.mu.Lock()
+	c.state.warmedUp = true
+	c.state.mu.Unlock()
+	return rsp
+}
+
+func (c *AppClient) warmingUp() bool {
+	c.state.mu.Lock()
+	defer c.state.mu.Unlock()
+	return !c.state.warmedUp && c.warmup > 0
+}
+
+func (c *AppClient) send(req *http.Request, body []byte) (*AppResponse, error) {
+	httpRsp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpRsp.Body.Close()
+	data, err := io.ReadAll(httpRsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &AppResponse{
+		t:        c.t,
+		Response: httpRsp,
+		Body:     data,
+		request:  req.Method + " " + req.URL.String(),
+	}
+	c.state.mu.Lock()
+	c.state.exchanges = append(c.state.exchanges, formatExchange(req, body, httpRsp, data))
+	c.state.mu.Unlock()
+	return rsp, nil
+}
+
+// logExchanges logs requests and responses of the client if the test failed
+func (c *AppClient) logExchanges() {
+	if !c.t.Failed() {
+		return
+	}
+	c.state.mu.Lock()
+	defer c.state.mu.Unlock()
+	if len(c.state.exchanges) != 0 {
+		c.t.Logf("requests to %s:\n%s", c.cfg.ServiceName(), strings.Join(c.state.exchanges, ""))
+	}
+}
+
+// Status checks the status code
+func (r *AppResponse) Status(expected int) *AppResponse {
+	r.t.Helper()
+	if r.Response.StatusCode != expected {
+		r.t.Errorf("%s: status is %d, expected %d", r.request, r.Response.StatusCode, expected)
+	}
+	return r
+}
+
+// Header checks the value of the response header
+func (r *AppResponse) Header(key, expected string) *AppResponse {
+	r.t.Helper()
+	if got := r.Response.Header.Get(key); got != expected {
+		r.t.Errorf("%s: header %s is %q, expected %q", r.request, key, got, expected)
+	}
+	return r
+}
+
+// JSONEq checks the body is equal to the expected JSON document ignoring formatting and key order
+func (r *AppResponse) JSONEq(expected interface{}) *AppResponse {
+	r.t.Helper()
+	if m := gtt.JSONEq(expected); !m.Matches(r.Body) {
+		r.t.Errorf("%s: body does not match %s\n%s", r.request, m, m.Got(r.Body))
+	}
+	return r
+}
+
+// JSONPath checks the value at the path of the JSON body, e.g. "items.0.id" or "$.items[0].id".
+// Values are compared as decoded JSON, so numbers of any Go type match.
+func (r *AppResponse) JSONPath(path string, expected interface{}) *AppResponse {
+	r.t.Helper()
+	got, ok := r.lookup(path)
+	if !ok {
+		return r
+	}
+	want, err := normalizeJSON(expected)
+	if err != nil {
+		r.t.Errorf("%s: failed to encode expected value of %s: %v", r.request, path, err)
+		return r
+	}
+	if !reflect.DeepEqual(got, want) {
+		r.t.Errorf("%s: value at %s is %v, expected %v", r.request, path, got, want)
+	}
+	return r
+}
+
+// JSONPathMatches checks the value at the path of the JSON body with the predicate
+func (r *AppResponse) JSONPathMatches(path string, pred func(v interface{}) bool) *AppResponse {
+	r.t.Helper()
+	if got, ok := r.lookup(path); ok && !pred(got) {
+		r.t.Errorf("%s: value %v at %s does not match predicate", r.request, got, path)
+	}
+	return r
+}
+
+// DecodeJSON decodes the body to v, the test fails at once if the body is not valid JSON
+func (r *AppResponse) DecodeJSON(v interface{}) *AppResponse {
+	r.t.Helper()
+	require.NoError(r.t, json.Unmarshal(r.Body, v), "%s: failed to decode body", r.request)
+	return r
+}
+
+func (r *AppResponse) lookup(path string) (interface{}, bool) {
+	r.t.Helper()
+	if !r.decoded {
+		r.decoded = true
+		r.docErr = json.Unmarshal(r.Body, &r.doc)
+	}
+	if r.docErr != nil {
+		r.t.Errorf("%s: body is not JSON: %v", r.request, r.docErr)
+		return nil, false
+	}
+	got, ok := gtt.LookupJSONPath(r.doc, path)
+	if !ok {
+		r.t.Errorf("%s: no value at %s", r.request, path)
+	}
+	return got, ok
+}
+
+func encodeBody(body interface{}) (data []byte, contentType string, err error) {
+	switch v := body.(type) {
+	case nil:
+		return nil, "", nil
+	case []byte:
+		return v, "", nil
+	case string:
+		return []byte(v), "", nil
+	default:
+		data, err = json.Marshal(v)
+		return data, "application/json", err
+	}
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// isDialError reports whether the connection was not established, so the request was not sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+func formatExchange(req *http.Request, reqBody []byte, rsp *http.Response, rspBody []byte) string {
+	var buf strings.Builder
+	buf.WriteString("-----------------\n")
+	buf.WriteString(fmt.Sprintf("request: %s %s\n", req.Method, req.URL))
+	writeHeaders(&buf, req.Header)
+	if len(reqBody) > 0 {
+		buf.WriteString(fmt.Sprintf("req body: %s\n", truncateExchangeBody(reqBody)))
+	}
+	buf.WriteString(fmt.Sprintf("status: %d\n", rsp.StatusCode))
+	writeHeaders(&buf, rsp.Header)
+	if len(rspBody) > 0 {
+		buf.WriteString(fmt.Sprintf("rsp body: %s\n", truncateExchangeBody(rspBody)))
+	}
+	return buf.String()
+}
+
+func writeHeaders(buf *strings.Builder, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf("	%s: %s\n", k, h[k]))
+	}
+}
+
+func truncateExchangeBody(body []byte) string {
+	if len(body) > exchangeBodyLimit {
+		return string(body[:exchangeBodyLimit]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,127 @@
+package testutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServiceConfig struct {
+	ports map[string]string
+}
+
+func (c *fakeServiceConfig) ServiceName() string              { return "orders" }
+func (c *fakeServiceConfig) BinaryPath() string               { return "" }
+func (c *fakeServiceConfig) TransportPort(name string) string { return c.ports[name] }
+
+// failureTB records failures and cleanups of the client instead of failing the test
+type failureTB struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+	logs     []string
+}
+
+func (f *failureTB) Helper()      {}
+func (f *failureTB) Failed() bool { return len(f.errors) != 0 }
+func (f *failureTB) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+func (f *failureTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+func (f *failureTB) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func newAppServer(t *testing.T, handler http.HandlerFunc) *fakeServiceConfig {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	return &fakeServiceConfig{ports: map[string]string{"publicapi": port}}
+}
+
+func TestAppClient(t *testing.T) {
+	// the app starts listening after the first dial
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	cfg := &fakeServiceConfig{ports: map[string]string{"publicapi": port}}
+
+	var calls atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"order":{"id":7,"status":"new","items":[{"sku":"a"}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		if l, err := net.Listen("tcp", addr); err == nil {
+			srv.Listener = l
+			srv.Start()
+		}
+	}()
+
+	client := NewAppClient(t, cfg).WithHeader("Authorization", "Bearer secret")
+	var created struct {
+		Order struct {
+			ID int `json:"id"`
+		} `json:"order"`
+	}
+	client.Post("publicapi", "/v1/orders", map[string]interface{}{"sku": "a"}).
+		Status(http.StatusCreated).
+		Header("Content-Type", "application/json").
+		JSONPath("order.id", 7).
+		JSONPath("$.order.items[0]", map[string]string{"sku": "a"}).
+		JSONPathMatches("order.status", func(v interface{}) bool { return v == "new" }).
+		JSONEq(`{"order": {"status": "new", "id": 7, "items": [{"sku": "a"}]}}`).
+		DecodeJSON(&created)
+	require.Equal(t, 7, created.Order.ID)
+	require.Equal(t, int32(1), calls.Load(), "the request is sent once")
+
+	client.Post("publicapi", "/v1/orders", []byte("{}")).Status(http.StatusBadRequest)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestAppClientFailure(t *testing.T) {
+	var calls atomic.Int32
+	cfg := newAppServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"starting"}`))
+	})
+
+	tb := &failureTB{TB: t}
+	client := NewAppClient(tb, cfg, WithWarmupTimeout(100*time.Millisecond))
+	client.Get("publicapi", "/v1/orders/7").
+		Status(http.StatusOK).
+		JSONPath("order.id", 7)
+	require.Len(t, tb.errors, 2)
+	require.Contains(t, tb.errors[0], "/v1/orders/7: status is 503, expected 200")
+	require.Contains(t, tb.errors[1], "no value at order.id")
+	require.Equal(t, int32(1), calls.Load(), "statuses are not retried")
+
+	for _, fn := range tb.cleanups {
+		fn()
+	}
+	require.Len(t, tb.logs, 1)
+	require.True(t, strings.HasPrefix(tb.logs[0], "requests to orders:\n"))
+	require.Contains(t, tb.logs[0], `rsp body: {"error":"starting"}`)
+}
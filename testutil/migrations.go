@@ -0,0 +1,319 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Dialect is the SQL dialect of the database, it defines placeholders and the versions table layout
+type Dialect int
+
+const (
+	DialectPostgres Dialect = iota
+	DialectMySQL
+	DialectClickHouse
+)
+
+// DefaultMigrationsTable is the versions table of golang-migrate, goat.GolangMigrateDefault can wait for it
+const DefaultMigrationsTable = "schema_migrations"
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type (
+	// Migration is a pair of up and down SQL scripts of one version
+	Migration struct {
+		Name    string
+		Up      string
+		Down    string
+		Version uint64
+		HasDown bool
+	}
+
+	// Migrator applies SQL migrations in golang-migrate layout: {version}_{title}.up.sql and {version}_{title}.down.sql.
+	// The version is stored in the golang-migrate versions table, so the app and migrate CLI see the same state.
+	//
+	// Scripts are executed with a single Exec call, the driver must support multiple statements
+	// (e.g. multiStatements=true for MySQL).
+	Migrator struct {
+		table      string
+		migrations []Migration
+		dialect    Dialect
+	}
+
+	// MigratorOption configures Migrator
+	MigratorOption func(m *Migrator)
+)
+
+var _ MigrationRunner = (*Migrator)(nil)
+
+// WithMigrationsTable sets the versions table name, schema_migrations by default
+func WithMigrationsTable(table string) MigratorOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithDialect sets the SQL dialect, Postgres by default
+func WithDialect(d Dialect) MigratorOption {
+	return func(m *Migrator) {
+		m.dialect = d
+	}
+}
+
+// NewMigratorFromDir reads migrations from the directory
+func NewMigratorFromDir(dir string, opts ...MigratorOption) (*Migrator, error) {
+	return NewMigrator(os.DirFS(dir), opts...)
+}
+
+// NewMigrator reads migrations from the root of fsys, e.g. embed.FS or os.DirFS
+func NewMigrator(fsys fs.FS, opts ...MigratorOption) (*Migrator, error) {
+	m := &Migrator{
+		table:   DefaultMigrationsTable,
+		dialect: DialectPostgres,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, e := range entries {
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", e.Name())
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+			mig.HasDown = true
+		}
+	}
+
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		m.migrations = append(m.migrations, *mig)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	return m, nil
+}
+
+// Migrations returns migrations ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// ApplyMigrations implements MigrationRunner, it applies all migrations
+func (m *Migrator) ApplyMigrations(ctx context.Context, db *sql.DB) error {
+	return m.Up(ctx, db)
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context, db *sql.DB) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.MigrateTo(ctx, db, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down rolls back all applied migrations
+func (m *Migrator) Down(ctx context.Context, db *sql.DB) error {
+	return m.MigrateTo(ctx, db, 0)
+}
+
+// Version returns the current version, zero if no migrations are applied
+func (m *Migrator) Version(ctx context.Context, db *sql.DB) (version uint64, dirty bool, err error) {
+	if err := m.ensureTable(ctx, db); err != nil {
+		return 0, false, err
+	}
+
+	query := fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", m.table)
+	if m.dialect == DialectClickHouse {
+		query = fmt.Sprintf("SELECT version, dirty FROM %s ORDER BY sequence DESC LIMIT 1", m.table)
+	}
+
+	var v int64
+	err = db.QueryRowContext(ctx, query).Scan(&v, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	if v <= 0 {
+		return 0, dirty, nil
+	}
+	return uint64(v), dirty, nil
+}
+
+// MigrateTo applies or rolls back migrations to reach the version, zero rolls back everything
+func (m *Migrator) MigrateTo(ctx context.Context, db *sql.DB, target uint64) error {
+	if target != 0 && m.index(target) < 0 {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+
+	current, dirty, err := m.Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty, fix the database and the version manually", current)
+	}
+
+	for _, mig := range m.migrations {
+		if mig.Version > current && mig.Version <= target {
+			if err := m.run(ctx, db, mig.Version, mig.Up); err != nil {
+				return fmt.Errorf("migration %d_%s up failed: %w", mig.Version, mig.Name, err)
+			}
+		}
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version <= target || mig.Version > current {
+			continue
+		}
+		if !mig.HasDown {
+			return fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+		var prev uint64
+		if i > 0 {
+			prev = m.migrations[i-1].Version
+		}
+		if err := m.run(ctx, db, prev, mig.Down); err != nil {
+			return fmt.Errorf("migration %d_%s down failed: %w", mig.Version, mig.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) index(version uint64) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// run executes the script, the resulting version is dirty until the script succeeds like in golang-migrate
+func (m *Migrator) run(ctx context.Context, db *sql.DB, version uint64, script string) error {
+	if err := m.setVersion(ctx, db, version, true); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	return m.setVersion(ctx, db, version, false)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, db *sql.DB) error {
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)", m.table)
+	if m.dialect == DialectClickHouse {
+		query = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version Int64, dirty UInt8, sequence UInt64) ENGINE = TinyLog", m.table)
+	}
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	return nil
+}
+
+// setVersion stores the version, no version is stored as -1 if it is dirty like in golang-migrate
+func (m *Migrator) setVersion(ctx context.Context, db *sql.DB, version uint64, dirty bool) error {
+	v := int64(version) //nolint:gosec // versions fit int64
+	if v == 0 {
+		v = -1
+	}
+
+	if m.dialect == DialectClickHouse {
+		query := fmt.Sprintf("INSERT INTO %s (version, dirty, sequence) VALUES (%s, %s, %s)",
+			m.table, m.dialect.Placeholder(1), m.dialect.Placeholder(2), m.dialect.Placeholder(3))
+		var d uint8
+		if dirty {
+			d = 1
+		}
+		if _, err := db.ExecContext(ctx, query, v, d, uint64(time.Now().UnixNano())); err != nil { //nolint:gosec // time is positive
+			return fmt.Errorf("failed to set migration version: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to set migration version: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+m.table); err != nil {
+		return fmt.Errorf("failed to set migration version: %w", err)
+	}
+	if v > 0 || dirty {
+		query := fmt.Sprintf("INSERT INTO %s (version, dirty) VALUES (%s, %s)", m.table, m.dialect.Placeholder(1), m.dialect.Placeholder(2))
+		if _, err := tx.ExecContext(ctx, query, v, dirty); err != nil {
+			return fmt.Errorf("failed to set migration version: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Placeholder returns the n-th (1-based) bind parameter placeholder
+func (d Dialect) Placeholder(n int) string {
+	if d == DialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// VerifyReversibleMigrations checks every migration by applying it, rolling it back and applying it again.
+// The database ends up migrated to the last version.
+func VerifyReversibleMigrations(t testing.TB, db *sql.DB, m *Migrator) {
+	t.Helper()
+	ctx := context.Background()
+
+	var prev uint64
+	for _, mig := range m.migrations {
+		require.NoError(t, m.MigrateTo(ctx, db, mig.Version), "migration %d_%s is not applied", mig.Version, mig.Name)
+		require.True(t, mig.HasDown, "migration %d_%s has no down script", mig.Version, mig.Name)
+		require.NoError(t, m.MigrateTo(ctx, db, prev), "migration %d_%s is not rolled back", mig.Version, mig.Name)
+		require.NoError(t, m.MigrateTo(ctx, db, mig.Version), "migration %d_%s is not applied after rollback", mig.Version, mig.Name)
+		prev = mig.Version
+	}
+}
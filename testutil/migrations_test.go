@@ -0,0 +1,133 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"1_users.up.sql":     {Data: []byte("CREATE TABLE users (id bigint)")},
+		"1_users.down.sql":   {Data: []byte("DROP TABLE users")},
+		"2_orders.up.sql":    {Data: []byte("CREATE TABLE orders (id bigint)")},
+		"2_orders.down.sql":  {Data: []byte("DROP TABLE orders")},
+		"10_index.up.sql":    {Data: []byte("CREATE INDEX orders_id ON orders (id)")},
+		"10_index.down.sql":  {Data: []byte("DROP INDEX orders_id")},
+		"README.md":          {Data: []byte("not a migration")},
+		"seed/1_data.up.sql": {Data: []byte("ignored, nested")},
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db, fake := newFakeDB(t)
+	ctx := context.Background()
+
+	m, err := NewMigrator(testMigrations())
+	require.NoError(t, err)
+
+	versions := make([]uint64, 0, 3)
+	for _, mig := range m.Migrations() {
+		versions = append(versions, mig.Version)
+	}
+	require.Equal(t, []uint64{1, 2, 10}, versions)
+
+	require.NoError(t, m.MigrateTo(ctx, db, 2))
+	version, dirty, err := m.Version(ctx, db)
+	require.NoError(t, err)
+	require.False(t, dirty)
+	require.Equal(t, uint64(2), version)
+
+	require.NoError(t, m.ApplyMigrations(ctx, db))
+	version, _, err = m.Version(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), version)
+
+	require.NoError(t, m.MigrateTo(ctx, db, 1))
+	require.NoError(t, m.Down(ctx, db))
+	version, _, err = m.Version(ctx, db)
+	require.NoError(t, err)
+	require.Zero(t, version)
+
+	require.Equal(t, []string{
+		"CREATE TABLE users (id bigint)",
+		"CREATE TABLE orders (id bigint)",
+		"CREATE INDEX orders_id ON orders (id)",
+		"DROP INDEX orders_id",
+		"DROP TABLE orders",
+		"DROP TABLE users",
+	}, fake.statements())
+
+	require.Error(t, m.MigrateTo(ctx, db, 3))
+}
+
+func TestMigratorDirty(t *testing.T) {
+	db, _ := newFakeDB(t)
+	ctx := context.Background()
+
+	fsys := testMigrations()
+	fsys["2_orders.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE orders FAIL")}
+	m, err := NewMigrator(fsys)
+	require.NoError(t, err)
+
+	require.ErrorContains(t, m.Up(ctx, db), "migration 2_orders up failed")
+	version, dirty, err := m.Version(ctx, db)
+	require.NoError(t, err)
+	require.True(t, dirty)
+	require.Equal(t, uint64(2), version)
+
+	require.ErrorContains(t, m.Up(ctx, db), "migration 2 is dirty")
+}
+
+func TestNewMigratorErrors(t *testing.T) {
+	_, err := NewMigrator(fstest.MapFS{"1_users.down.sql": {Data: []byte("DROP TABLE users")}})
+	require.ErrorContains(t, err, "has no up script")
+
+	_, err = NewMigrator(fstest.MapFS{
+		"1_users.up.sql":  {Data: []byte("CREATE TABLE users (id bigint)")},
+		"1_orders.up.sql": {Data: []byte("CREATE TABLE orders (id bigint)")},
+	})
+	require.ErrorContains(t, err, "is used by")
+}
+
+func TestVerifyReversibleMigrations(t *testing.T) {
+	db, _ := newFakeDB(t)
+	m, err := NewMigrator(testMigrations())
+	require.NoError(t, err)
+
+	VerifyReversibleMigrations(t, db, m)
+
+	version, _, err := m.Version(context.Background(), db)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), version)
+}
+
+func TestVerifyReversibleMigrationsIrreversible(t *testing.T) {
+	db, _ := newFakeDB(t)
+	fsys := testMigrations()
+	fsys["2_orders.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+	m, err := NewMigrator(fsys)
+	require.NoError(t, err)
+
+	rec := &recordingTB{TB: t}
+	func() {
+		defer func() { _ = recover() }()
+		VerifyReversibleMigrations(rec, db, m)
+	}()
+	require.True(t, rec.failed)
+}
+
+// recordingTB records failures of helpers instead of failing the test
+type recordingTB struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingTB) Errorf(string, ...interface{}) { r.failed = true }
+func (r *recordingTB) FailNow() {
+	r.failed = true
+	panic("FailNow")
+}
+func (r *recordingTB) Helper() {}
@@ -0,0 +1,212 @@
+package testutil
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	gtt "github.com/Educentr/goat"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	defaultTemplatePrefix      = "goat_template"
+	defaultMaintenanceDatabase = "postgres"
+	// postgres limits identifiers to 63 bytes
+	maxDatabaseNameLength = 63
+)
+
+var databaseNameRe = regexp.MustCompile(`[^a-z0-9_]+`)
+
+type (
+	// PostgresTemplateConfig configures PostgresTemplate
+	PostgresTemplateConfig struct {
+		// DSN returns the connection string of the database by its name
+		DSN func(database string) string
+		// Prepare fills the template database once, e.g. Migrator.ApplyMigrations
+		Prepare func(ctx context.Context, db *sql.DB) error
+		// Driver is the sql driver name, e.g. "pgx" or "postgres"
+		Driver string
+		// Template is the template database name. By default it is unique per process, goat_template_<pid>_<random>,
+		// so test binaries of parallel packages sharing one server do not drop each other's template,
+		// and it is dropped by Close.
+		Template string
+		// MaintenanceDatabase is used to create and drop databases, postgres by default
+		MaintenanceDatabase string
+	}
+
+	// PostgresTemplate gives every test a fresh database cloned with CREATE DATABASE ... TEMPLATE.
+	// The template is prepared once, cloning is much faster than applying migrations or recreating schemas.
+	// Databases are dropped WITH (FORCE) at the end of the test, Postgres 13 or newer is required.
+	PostgresTemplate struct {
+		admin   *sql.DB
+		err     error
+		cfg     PostgresTemplateConfig
+		once    sync.Once
+		cloneMu sync.Mutex
+		// ownTemplate is set when the template name is generated, Close drops it
+		ownTemplate bool
+	}
+
+	// TestDatabase is a database cloned for one test
+	TestDatabase struct {
+		DB   *sql.DB
+		Name string
+		DSN  string
+	}
+)
+
+// NewPostgresTemplate creates a template, it is prepared by the first Clone
+func NewPostgresTemplate(cfg PostgresTemplateConfig) *PostgresTemplate {
+	ownTemplate := cfg.Template == ""
+	if ownTemplate {
+		cfg.Template = fmt.Sprintf("%s_%d_%s", defaultTemplatePrefix, os.Getpid(), randomSuffix())
+	}
+	if cfg.MaintenanceDatabase == "" {
+		cfg.MaintenanceDatabase = defaultMaintenanceDatabase
+	}
+	return &PostgresTemplate{cfg: cfg, ownTemplate: ownTemplate}
+}
+
+// Template returns the name of the template database
+func (p *PostgresTemplate) Template() string {
+	return p.cfg.Template
+}
+
+// Prepare recreates the template database and fills it, it is done once, later calls return the first result
+func (p *PostgresTemplate) Prepare(ctx context.Context) error {
+	p.once.Do(func() {
+		p.err = p.prepare(ctx)
+	})
+	return p.err
+}
+
+func (p *PostgresTemplate) prepare(ctx context.Context) error {
+	admin, err := sql.Open(p.cfg.Driver, p.cfg.DSN(p.cfg.MaintenanceDatabase))
+	if err != nil {
+		return fmt.Errorf("failed to connect to maintenance database: %w", err)
+	}
+	p.admin = admin
+
+	template := quoteIdent(p.cfg.Template)
+	if _, err := admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+template+" WITH (FORCE)"); err != nil {
+		return fmt.Errorf("failed to drop template database: %w", err)
+	}
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+template); err != nil {
+		return fmt.Errorf("failed to create template database: %w", err)
+	}
+
+	if p.cfg.Prepare == nil {
+		return nil
+	}
+
+	db, err := sql.Open(p.cfg.Driver, p.cfg.DSN(p.cfg.Template))
+	if err != nil {
+		return fmt.Errorf("failed to connect to template database: %w", err)
+	}
+	// the template must have no connections while it is cloned
+	defer db.Close()
+
+	if err := p.cfg.Prepare(ctx, db); err != nil {
+		return fmt.Errorf("failed to prepare template database: %w", err)
+	}
+	return nil
+}
+
+// Clone creates a database for the test from the template and drops it when the test finishes
+func (p *PostgresTemplate) Clone(t testing.TB) *TestDatabase {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, p.Prepare(ctx))
+
+	name := testDatabaseName(t.Name())
+
+	// concurrent clones of one template fail with "source database is being accessed by other users"
+	p.cloneMu.Lock()
+	_, err := p.admin.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", quoteIdent(name), quoteIdent(p.cfg.Template)))
+	p.cloneMu.Unlock()
+	require.NoError(t, err, "failed to clone template database")
+
+	dsn := p.cfg.DSN(name)
+	db, err := sql.Open(p.cfg.Driver, dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = db.Close()
+		_, err := p.admin.ExecContext(context.Background(), "DROP DATABASE IF EXISTS "+quoteIdent(name)+" WITH (FORCE)")
+		require.NoError(t, err, "failed to drop test database %s", name)
+	})
+
+	return &TestDatabase{
+		Name: name,
+		DSN:  dsn,
+		DB:   db,
+	}
+}
+
+// Close drops the generated template database and closes the maintenance connection,
+// cloned databases are dropped by test cleanups. Call it from TestMain after m.Run.
+func (p *PostgresTemplate) Close() error {
+	if p.admin == nil {
+		return nil
+	}
+	var dropErr error
+	if p.ownTemplate {
+		_, dropErr = p.admin.ExecContext(context.Background(), "DROP DATABASE IF EXISTS "+quoteIdent(p.cfg.Template)+" WITH (FORCE)")
+		if dropErr != nil {
+			dropErr = fmt.Errorf("failed to drop template database: %w", dropErr)
+		}
+	}
+	return errors.Join(dropErr, p.admin.Close())
+}
+
+// NewIsolatedFlow creates a flow with a database cloned for the test.
+// The app is built when mocks are listening and gets the test database, e.g. to pass its DSN via env:
+//
+//	flow, db := testutil.NewIsolatedFlow(t, env, tpl, func(mocks *gtt.MocksHandler, db *testutil.TestDatabase) gtt.BaseExecutor {
+//		return gtt.NewExecutorBuilder(binary).WithEnvVar("DB_DSN", db.DSN).Build()
+//	}, httpMocks, nil)
+func NewIsolatedFlow(
+	t *testing.T,
+	env *gtt.Env,
+	tpl *PostgresTemplate,
+	app func(mocks *gtt.MocksHandler, db *TestDatabase) gtt.BaseExecutor,
+	hcb gtt.HTTPCB,
+	gCb gtt.GrpcCB,
+	opts ...gtt.MocksOption,
+) (*gtt.Flow, *TestDatabase) {
+	t.Helper()
+	db := tpl.Clone(t)
+	flow := gtt.NewFlowWithApp(t, env, func(mocks *gtt.MocksHandler) gtt.BaseExecutor {
+		return app(mocks, db)
+	}, hcb, gCb, opts...)
+	return flow, db
+}
+
+// testDatabaseName returns a unique database name with a readable test name part
+func testDatabaseName(testName string) string {
+	base := databaseNameRe.ReplaceAllString(strings.ToLower(testName), "_")
+	tail := "_" + randomSuffix()
+	if maxBase := maxDatabaseNameLength - len("goat_") - len(tail); len(base) > maxBase {
+		base = base[:maxBase]
+	}
+	return "goat_" + base + tail
+}
+
+func randomSuffix() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix) //nolint:errcheck // crypto/rand never fails
+	return hex.EncodeToString(suffix)
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
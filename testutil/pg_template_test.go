@@ -0,0 +1,60 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresTemplate(t *testing.T) {
+	_, fake := newFakeDB(t)
+	var prepared int
+	tpl := NewPostgresTemplate(PostgresTemplateConfig{
+		Driver: "goat-testutil-fake",
+		DSN:    func(string) string { return t.Name() },
+		Prepare: func(ctx context.Context, db *sql.DB) error {
+			prepared++
+			_, err := db.ExecContext(ctx, "CREATE TABLE users (id bigint)")
+			return err
+		},
+	})
+	template := tpl.Template()
+	require.Regexp(t, `^goat_template_[0-9]+_[0-9a-f]{8}$`, template)
+	require.NotEqual(t, template, NewPostgresTemplate(PostgresTemplateConfig{}).Template())
+
+	var names []string
+	for _, name := range []string{"first", "Second/With Spaces"} {
+		t.Run(name, func(t *testing.T) {
+			db := tpl.Clone(t)
+			require.NotNil(t, db.DB)
+			names = append(names, db.Name)
+		})
+	}
+
+	require.Equal(t, 1, prepared)
+	require.Len(t, names, 2)
+	require.True(t, strings.HasPrefix(names[0], "goat_testpostgrestemplate_first_"), names[0])
+	require.True(t, strings.HasPrefix(names[1], "goat_testpostgrestemplate_second_with_spaces_"), names[1])
+
+	require.NoError(t, tpl.Close())
+	require.Equal(t, []string{
+		`DROP DATABASE IF EXISTS "` + template + `" WITH (FORCE)`,
+		`CREATE DATABASE "` + template + `"`,
+		"CREATE TABLE users (id bigint)",
+		`CREATE DATABASE "` + names[0] + `" TEMPLATE "` + template + `"`,
+		`DROP DATABASE IF EXISTS "` + names[0] + `" WITH (FORCE)`,
+		`CREATE DATABASE "` + names[1] + `" TEMPLATE "` + template + `"`,
+		`DROP DATABASE IF EXISTS "` + names[1] + `" WITH (FORCE)`,
+		`DROP DATABASE IF EXISTS "` + template + `" WITH (FORCE)`,
+	}, fake.statements())
+}
+
+func TestTestDatabaseName(t *testing.T) {
+	name := testDatabaseName(strings.Repeat("VeryLongTestName/", 10))
+	require.LessOrEqual(t, len(name), maxDatabaseNameLength)
+	require.Regexp(t, `^goat_verylongtestname_[a-z0-9_]*_[0-9a-f]{8}$`, name)
+	require.NotEqual(t, name, testDatabaseName(strings.Repeat("VeryLongTestName/", 10)))
+}
@@ -0,0 +1,227 @@
+package goat
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	MockCACommonName     = "goat-mock-ca"
+	MockServerCommonName = "goat-mock-server"
+	MockClientCommonName = "goat-mock-client"
+
+	certValidity = 24 * time.Hour
+)
+
+// MockCertificates is a throwaway PKI for TLS mock servers.
+// All certificates and keys are written as PEM files, so their paths can be passed to the app via env.
+//
+//nolint:govet // fieldalignment: struct optimization not worth the readability cost
+type MockCertificates struct {
+	CAFile         string
+	ServerCertFile string
+	ServerKeyFile  string
+	ClientCertFile string
+	ClientKeyFile  string
+
+	caPool     *x509.CertPool
+	clientCAs  *x509.CertPool
+	serverCert tls.Certificate
+	clientCert tls.Certificate
+}
+
+type certificateTemplate struct {
+	parent    *x509.Certificate
+	parentKey *ecdsa.PrivateKey
+	template  *x509.Certificate
+	certFile  string
+	keyFile   string
+}
+
+// GenerateMockCertificates creates a CA with server and client leaf certificates in dir.
+// The server certificate is valid for the given hosts (IP addresses or DNS names),
+// 127.0.0.1 and localhost are used if no hosts are given.
+func GenerateMockCertificates(dir string, hosts ...string) (*MockCertificates, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"127.0.0.1", "localhost"}
+	}
+
+	c := &MockCertificates{
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		ServerCertFile: filepath.Join(dir, "server.pem"),
+		ServerKeyFile:  filepath.Join(dir, "server-key.pem"),
+		ClientCertFile: filepath.Join(dir, "client.pem"),
+		ClientKeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	caTemplate := newCertTemplate(MockCACommonName)
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+
+	ca, caKey, err := issueCertificate(certificateTemplate{template: caTemplate, certFile: c.CAFile})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA: %w", err)
+	}
+	c.caPool = x509.NewCertPool()
+	c.caPool.AddCert(ca)
+	c.clientCAs = x509.NewCertPool()
+	c.clientCAs.AddCert(ca)
+
+	serverTemplate := newCertTemplate(MockServerCommonName)
+	serverTemplate.KeyUsage = x509.KeyUsageDigitalSignature
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+
+	if _, _, err = issueCertificate(certificateTemplate{
+		template: serverTemplate, parent: ca, parentKey: caKey,
+		certFile: c.ServerCertFile, keyFile: c.ServerKeyFile,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to generate server certificate: %w", err)
+	}
+
+	clientTemplate := newCertTemplate(MockClientCommonName)
+	clientTemplate.KeyUsage = x509.KeyUsageDigitalSignature
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	if _, _, err = issueCertificate(certificateTemplate{
+		template: clientTemplate, parent: ca, parentKey: caKey,
+		certFile: c.ClientCertFile, keyFile: c.ClientKeyFile,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to generate client certificate: %w", err)
+	}
+
+	if c.serverCert, err = tls.LoadX509KeyPair(c.ServerCertFile, c.ServerKeyFile); err != nil {
+		return nil, err
+	}
+	if c.clientCert, err = tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// AddClientCA trusts client certificates issued by the CA in the PEM file in addition to the generated CA.
+func (c *MockCertificates) AddClientCA(caFile string) error {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	if !c.clientCAs.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return nil
+}
+
+// ServerTLSConfig returns the config for mock servers. Client certificates are required and verified if clientAuth is true.
+func (c *MockCertificates) ServerTLSConfig(clientAuth bool) *tls.Config {
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{c.serverCert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientAuth {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = c.clientCAs
+	}
+	return cfg
+}
+
+// ClientTLSConfig returns the config for calling mock servers from the test itself, it presents the generated client certificate.
+func (c *MockCertificates) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.clientCert},
+		RootCAs:      c.caPool,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// AssertClientCertSubject checks that the client presented a certificate with the common name.
+func AssertClientCertSubject(t testing.TB, cert *x509.Certificate, commonName string) bool {
+	t.Helper()
+	if cert == nil {
+		return assert.Fail(t, "client certificate is missing", "expected certificate with CN=%s", commonName)
+	}
+	return assert.Equal(t, commonName, cert.Subject.CommonName, "client certificate subject mismatch: %s", cert.Subject)
+}
+
+func newCertTemplate(commonName string) *x509.Certificate {
+	now := time.Now()
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{"goat"},
+		},
+		NotBefore: now.Add(-time.Minute),
+		NotAfter:  now.Add(certValidity),
+	}
+}
+
+func issueCertificate(ct certificateTemplate) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	ct.template.SerialNumber = serial
+
+	parent, parentKey := ct.parent, ct.parentKey
+	if parent == nil {
+		parent, parentKey = ct.template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, ct.template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = writePEM(ct.certFile, "CERTIFICATE", der); err != nil {
+		return nil, nil, err
+	}
+	if ct.keyFile != "" {
+		keyDER, marshalErr := x509.MarshalPKCS8PrivateKey(key)
+		if marshalErr != nil {
+			return nil, nil, marshalErr
+		}
+		if err = writePEM(ct.keyFile, "PRIVATE KEY", keyDER); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return cert, key, nil
+}
+
+func writePEM(path, blockType string, der []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pem.Encode(f, &pem.Block{Type: blockType, Bytes: der})
+}
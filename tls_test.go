@@ -0,0 +1,48 @@
+package goat
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMockHandlerMTLS(t *testing.T) {
+	certs, err := GenerateMockCertificates(t.TempDir())
+	require.NoError(t, err)
+
+	h, err := NewHTTPMockHandler("tcp", "127.0.0.1:0", func(server *http.ServeMux) {
+		server.HandleFunc("/ping", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("pong"))
+		})
+	})
+	require.NoError(t, err)
+	h.EnableTLS(certs.ServerTLSConfig(true))
+	go func() {
+		_ = h.Start()
+	}()
+	defer func() {
+		_ = h.Stop()
+	}()
+
+	url := "https://" + h.Addr() + "/ping"
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: certs.ClientTLSConfig()}}
+	rsp, err := client.Get(url)
+	require.NoError(t, err)
+	body, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	require.NoError(t, rsp.Body.Close())
+	require.Equal(t, "pong", string(body))
+
+	clientCerts := h.ClientCertificates()
+	require.Len(t, clientCerts, 1)
+	AssertClientCertSubject(t, clientCerts[0], MockClientCommonName)
+
+	noCertConfig := certs.ClientTLSConfig()
+	noCertConfig.Certificates = nil
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: noCertConfig}}
+	_, err = client.Get(url) //nolint:bodyclose // request must fail
+	require.Error(t, err)
+}
@@ -1,10 +1,13 @@
 package goat
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"time"
+
+	"github.com/Educentr/goat/wait"
 )
 
 type MigrationWaitConfig interface {
@@ -70,7 +73,7 @@ func (p *PostgresqlWaitConfig) Check(rows *sql.Rows) error {
 	}
 
 	if version < p.ExpectedVersion {
-		return fmt.Errorf("migration step failed, version is wrong")
+		return fmt.Errorf("migration step failed, version is %d, expected %d", version, p.ExpectedVersion)
 	}
 
 	return nil
@@ -94,7 +97,7 @@ func (p *MysqlWaitConfig) Check(rows *sql.Rows) error {
 	}
 
 	if version != p.ExpectedVersion {
-		return fmt.Errorf("migration step failed, version is wrong")
+		return fmt.Errorf("migration step failed, version is %s, expected %s", version, p.ExpectedVersion)
 	}
 
 	return nil
@@ -121,35 +124,51 @@ func WaitMigration(expectedVersion int, db *sql.DB, args ...func(cfg MigrationWa
 	return WaitAppMigration(db, cfg)
 }
 
+// WaitAppMigration polls the migrations table every Interval for Cycles times.
+// If migrations are not applied, the error wraps the last check error; permanent errors, e.g. a dirty migration, are returned at once.
 func WaitAppMigration(db *sql.DB, cfg MigrationWaitConfig) error {
+	var lastErr error
 	for range cfg.GetCycles() {
-		if err := func() error {
-			rows, err := db.Query(cfg.GetQuery())
-			if err != nil {
-				return err
-			}
-			defer rows.Close()
-
-			if !rows.Next() {
-				return errors.New("empty migrations table") //nolint:err113
-			}
-
-			if rows.Err() != nil {
-				return rows.Err()
-			}
-
-			checkErr := cfg.Check(rows)
-			if checkErr != nil {
-				return checkErr
-			}
-
-			return nil
-		}(); err == nil {
+		lastErr = checkMigration(context.Background(), db, cfg)
+		if lastErr == nil {
 			return nil
 		}
+		if wait.IsPermanent(lastErr) {
+			return lastErr
+		}
 
 		time.Sleep(cfg.GetInterval())
 	}
 
-	return errors.New("migration step failed, too long") //nolint:err113
+	return fmt.Errorf("migration step failed, too long: %w", lastErr)
+}
+
+// WaitAppMigrationContext waits for migrations until the context is done, checks are retried with exponential backoff.
+// Cycles and Interval of the config are not used.
+func WaitAppMigrationContext(ctx context.Context, db *sql.DB, cfg MigrationWaitConfig, opts ...wait.Option) error {
+	return wait.For(ctx, MigrationCondition(db, cfg), opts...)
+}
+
+// MigrationCondition returns a readiness condition checking the migrations table, it can be combined with other conditions.
+func MigrationCondition(db *sql.DB, cfg MigrationWaitConfig) wait.Condition {
+	return wait.Func("migrations", func(ctx context.Context) error {
+		return checkMigration(ctx, db, cfg)
+	})
+}
+
+func checkMigration(ctx context.Context, db *sql.DB, cfg MigrationWaitConfig) error {
+	rows, err := db.QueryContext(ctx, cfg.GetQuery())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return errors.New("empty migrations table") //nolint:err113
+	}
+
+	return cfg.Check(rows)
 }
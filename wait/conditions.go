@@ -0,0 +1,142 @@
+package wait
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const maxBodyInError = 256
+
+// TCP returns a condition met when the address accepts TCP connections.
+func TCP(addr string) Condition {
+	return Func("tcp "+addr, func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// HTTP returns a condition met when GET of the url responds with the status.
+func HTTP(url string, status int) Condition {
+	return HTTPBody(url, status, nil)
+}
+
+// HTTPBody returns a condition met when GET of the url responds with the status and the body matches the predicate.
+// A nil predicate accepts any body.
+func HTTPBody(url string, status int, pred func(body []byte) bool) Condition {
+	return Func(fmt.Sprintf("http %s %d", url, status), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			return Permanent(err)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer rsp.Body.Close()
+
+		body, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			return err
+		}
+		if rsp.StatusCode != status {
+			return fmt.Errorf("status is %d, want %d: %s", rsp.StatusCode, status, truncate(body))
+		}
+		if pred != nil && !pred(body) {
+			return fmt.Errorf("body does not match: %s", truncate(body))
+		}
+		return nil
+	})
+}
+
+// GRPCHealth returns a condition met when the gRPC health service reports SERVING for the service,
+// empty service is the overall server health. Insecure credentials are used if no dial options are passed.
+func GRPCHealth(addr, service string, dialOpts ...grpc.DialOption) Condition {
+	if len(dialOpts) == 0 {
+		dialOpts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return Func(fmt.Sprintf("grpc health %s %q", addr, service), func(ctx context.Context) error {
+		conn, err := grpc.NewClient(addr, dialOpts...)
+		if err != nil {
+			return Permanent(err)
+		}
+		defer conn.Close()
+
+		rsp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if rsp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("status is %s", rsp.GetStatus())
+		}
+		return nil
+	})
+}
+
+// SQL returns a condition met when the query returns a row accepted by the check.
+// The check is called for the first row, it must scan the row.
+func SQL(db *sql.DB, query string, check func(rows *sql.Rows) error) Condition {
+	return Func("sql "+query, func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			return errors.New("no rows") //nolint:err113
+		}
+		return check(rows)
+	})
+}
+
+// FileExists returns a condition met when the file exists.
+func FileExists(path string) Condition {
+	return Func("file "+path, func(context.Context) error {
+		_, err := os.Stat(path)
+		return err
+	})
+}
+
+// LogPattern returns a condition met when the file contains a match of the regular expression, e.g. a log line of the app.
+// An invalid pattern fails the condition permanently.
+func LogPattern(path, pattern string) Condition {
+	re, reErr := regexp.Compile(pattern)
+	return Func(fmt.Sprintf("log %s %q", path, pattern), func(context.Context) error {
+		if reErr != nil {
+			return Permanent(reErr)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !re.Match(data) {
+			return fmt.Errorf("pattern is not found in %d bytes", len(data))
+		}
+		return nil
+	})
+}
+
+func truncate(body []byte) string {
+	if len(body) > maxBodyInError {
+		return string(body[:maxBodyInError]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,26 @@
+// Package wait provides composable readiness conditions for integration tests.
+//
+// A Condition is checked by For until it succeeds, the context is done or the condition
+// returns a Permanent error. Checks are retried with exponential backoff, the timeout error
+// wraps the last error returned by the condition.
+//
+// # Basic Usage
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//
+//	err := wait.For(ctx, wait.All(
+//		wait.TCP("localhost:5432"),
+//		wait.HTTP("http://localhost:8080/health", http.StatusOK),
+//		wait.GRPCHealth("localhost:9090", ""),
+//	))
+//
+// # Custom Conditions
+//
+//	err := wait.For(ctx, wait.Func("cache warmed up", func(ctx context.Context) error {
+//		if !cache.Ready() {
+//			return errors.New("cache is empty")
+//		}
+//		return nil
+//	}), wait.WithInterval(100*time.Millisecond, time.Second))
+package wait
@@ -0,0 +1,228 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInitialInterval = 50 * time.Millisecond
+	defaultMaxInterval     = 2 * time.Second
+	defaultMultiplier      = 2
+	defaultTimeout         = 30 * time.Second
+)
+
+type (
+	// Condition is a readiness check, Check returns nil when the condition is met.
+	Condition interface {
+		Check(ctx context.Context) error
+		String() string
+	}
+
+	// Option configures For.
+	Option func(o *options)
+
+	options struct {
+		initialInterval time.Duration
+		maxInterval     time.Duration
+		timeout         time.Duration
+		multiplier      float64
+	}
+
+	funcCondition struct {
+		check func(ctx context.Context) error
+		name  string
+	}
+
+	allCondition []Condition
+	anyCondition []Condition
+
+	permanentError struct {
+		err error
+	}
+)
+
+// TimeoutError is returned when the condition is not met before the deadline.
+type TimeoutError struct {
+	Cause     error
+	Condition string
+	Attempts  int
+	Elapsed   time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("condition %q is not met after %d attempts in %s: %v",
+		e.Condition, e.Attempts, e.Elapsed.Round(time.Millisecond), e.Cause)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Cause
+}
+
+// Func returns a condition calling the function.
+func Func(name string, check func(ctx context.Context) error) Condition {
+	return &funcCondition{name: name, check: check}
+}
+
+func (f *funcCondition) Check(ctx context.Context) error {
+	return f.check(ctx)
+}
+
+func (f *funcCondition) String() string {
+	return f.name
+}
+
+// All returns a condition met when all conditions are met, conditions are checked in order.
+func All(conds ...Condition) Condition {
+	return allCondition(conds)
+}
+
+func (a allCondition) Check(ctx context.Context) error {
+	for _, c := range a {
+		if err := c.Check(ctx); err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+func (a allCondition) String() string {
+	return joinConditions("all", a)
+}
+
+// Any returns a condition met when at least one condition is met.
+func Any(conds ...Condition) Condition {
+	return anyCondition(conds)
+}
+
+func (a anyCondition) Check(ctx context.Context) error {
+	errs := make([]error, 0, len(a))
+	for _, c := range a {
+		err := c.Check(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", c, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (a anyCondition) String() string {
+	return joinConditions("any", a)
+}
+
+func joinConditions(op string, conds []Condition) string {
+	names := make([]string, 0, len(conds))
+	for _, c := range conds {
+		names = append(names, c.String())
+	}
+	return op + "(" + strings.Join(names, ", ") + ")"
+}
+
+// Permanent wraps the error to stop waiting immediately, e.g. on a failed migration.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether the error is wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// WithTimeout limits waiting if the context has no deadline, 30 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithInterval sets the initial and the maximal intervals between checks, 50ms and 2s by default.
+// A non-positive initial interval is replaced by the default one, the maximal interval is at least the initial one.
+func WithInterval(initial, maxInterval time.Duration) Option {
+	return func(o *options) {
+		o.initialInterval = initial
+		o.maxInterval = maxInterval
+	}
+}
+
+// WithMultiplier sets the interval growth factor, 2 by default. Multiplier 1 gives constant intervals,
+// smaller ones are treated as 1.
+func WithMultiplier(m float64) Option {
+	return func(o *options) {
+		o.multiplier = m
+	}
+}
+
+// For blocks until the condition is met.
+// It returns the permanent error of the condition or TimeoutError wrapping the last error of the condition.
+func For(ctx context.Context, cond Condition, opts ...Option) error {
+	o := options{
+		initialInterval: defaultInitialInterval,
+		maxInterval:     defaultMaxInterval,
+		multiplier:      defaultMultiplier,
+		timeout:         defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// a zero interval would turn waiting into a busy loop
+	if o.initialInterval <= 0 {
+		o.initialInterval = defaultInitialInterval
+	}
+	if o.maxInterval < o.initialInterval {
+		o.maxInterval = o.initialInterval
+	}
+	if o.multiplier < 1 {
+		o.multiplier = 1
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	interval := o.initialInterval
+	for attempt := 1; ; attempt++ {
+		err := cond.Check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return fmt.Errorf("%s: %w", cond, permanent.err)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return &TimeoutError{
+				Condition: cond.String(),
+				Attempts:  attempt,
+				Elapsed:   time.Since(started),
+				Cause:     err,
+			}
+		}
+
+		interval = time.Duration(float64(interval) * o.multiplier)
+		if interval > o.maxInterval {
+			interval = o.maxInterval
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestFor(t *testing.T) {
+	var attempts atomic.Int32
+	cond := Func("third attempt", func(context.Context) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	require.NoError(t, For(context.Background(), cond, WithInterval(time.Millisecond, 5*time.Millisecond)))
+	require.Equal(t, int32(3), attempts.Load())
+}
+
+func TestForTimeout(t *testing.T) {
+	errNotReady := errors.New("not ready")
+	cond := Func("never", func(context.Context) error { return errNotReady })
+
+	err := For(context.Background(), cond, WithTimeout(30*time.Millisecond), WithInterval(time.Millisecond, time.Millisecond))
+	require.ErrorIs(t, err, errNotReady)
+
+	var timeout *TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	require.Equal(t, "never", timeout.Condition)
+	require.Greater(t, timeout.Attempts, 1)
+}
+
+func TestForZeroInterval(t *testing.T) {
+	var attempts atomic.Int32
+	cond := Func("never", func(context.Context) error {
+		attempts.Add(1)
+		return errors.New("not ready")
+	})
+
+	// the default interval is used instead of a busy loop
+	err := For(context.Background(), cond, WithTimeout(120*time.Millisecond), WithInterval(0, 0), WithMultiplier(0))
+	var timeout *TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	require.LessOrEqual(t, attempts.Load(), int32(4))
+}
+
+func TestForPermanent(t *testing.T) {
+	errDirty := errors.New("dirty")
+	var attempts int
+	cond := Func("permanent", func(context.Context) error {
+		attempts++
+		return Permanent(errDirty)
+	})
+
+	err := For(context.Background(), cond)
+	require.ErrorIs(t, err, errDirty)
+	require.Equal(t, 1, attempts)
+}
+
+func TestAllAny(t *testing.T) {
+	ok := Func("ok", func(context.Context) error { return nil })
+	fail := Func("fail", func(context.Context) error { return errors.New("failed") })
+
+	require.NoError(t, All(ok, ok).Check(context.Background()))
+	require.ErrorContains(t, All(ok, fail).Check(context.Background()), "fail: failed")
+	require.NoError(t, Any(fail, ok).Check(context.Background()))
+	require.Error(t, Any(fail, fail).Check(context.Background()))
+	require.Equal(t, "all(ok, any(fail, ok))", All(ok, Any(fail, ok)).String())
+}
+
+func TestNetworkConditions(t *testing.T) {
+	var ready atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	require.NoError(t, TCP(srv.Listener.Addr().String()).Check(ctx))
+	require.ErrorContains(t, HTTP(srv.URL, http.StatusOK).Check(ctx), "status is 503")
+
+	ready.Store(true)
+	require.NoError(t, HTTP(srv.URL, http.StatusOK).Check(ctx))
+	require.NoError(t, HTTPBody(srv.URL, http.StatusOK, func(body []byte) bool {
+		return string(body) == `{"status":"ok"}`
+	}).Check(ctx))
+}
+
+func TestGRPCHealth(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(server, hs)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	cond := GRPCHealth(lis.Addr().String(), "orders")
+	require.Error(t, cond.Check(context.Background()))
+
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	require.NoError(t, For(context.Background(), cond, WithTimeout(time.Second)))
+}
+
+func TestFileConditions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	ctx := context.Background()
+
+	require.Error(t, FileExists(path).Check(ctx))
+	require.NoError(t, os.WriteFile(path, []byte("level=info msg=\"starting\"\n"), 0o600))
+	require.NoError(t, FileExists(path).Check(ctx))
+
+	cond := LogPattern(path, `msg="listening on :\d+"`)
+	require.Error(t, cond.Check(ctx))
+	require.NoError(t, os.WriteFile(path, []byte("level=info msg=\"listening on :8080\"\n"), 0o600))
+	require.NoError(t, cond.Check(ctx))
+	err := LogPattern(path, `msg="(listening`).Check(ctx)
+	require.True(t, IsPermanent(err))
+	require.ErrorContains(t, err, "missing closing )")
+}
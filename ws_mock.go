@@ -0,0 +1,255 @@
+package goat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WSInbound marks frames sent by the client to the mock
+	WSInbound = "in"
+	// WSOutbound marks frames sent by the mock to the client
+	WSOutbound = "out"
+)
+
+type (
+	// WSFrame is a transcript record of a WebSocket message.
+	WSFrame struct {
+		At        time.Time
+		Direction string
+		Data      []byte
+		// Type is websocket.TextMessage, websocket.BinaryMessage or websocket.CloseMessage
+		Type int
+	}
+
+	// WSMock is an http.Handler serving a scripted WebSocket conversation.
+	// Register it on the mock ServeMux, the script is run for every accepted connection:
+	//
+	//	ws := gtt.NewWSMock().
+	//		ExpectMessage("subscribe", gtt.WSMessageContains("subscribe")).
+	//		SendText(`{"event":"subscribed"}`).
+	//		WaitTrigger("price").
+	//		SendText(`{"price":100}`).
+	//		Close(websocket.CloseNormalClosure, "bye")
+	//	server.Handle("/ws", ws)
+	WSMock struct {
+		script     *streamScript[*wsConn]
+		conn       *wsConn
+		transcript []WSFrame
+		upgrader   websocket.Upgrader
+		m          sync.Mutex
+	}
+
+	wsConn struct {
+		conn     *websocket.Conn
+		mock     *WSMock
+		incoming chan []byte
+		closed   chan struct{}
+		// done is closed when the script is over, messages are only recorded after that
+		done chan struct{}
+		wm   sync.Mutex
+	}
+)
+
+// NewWSMock creates an empty WebSocket script.
+func NewWSMock() *WSMock {
+	return &WSMock{
+		script: newStreamScript[*wsConn](),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+	}
+}
+
+// WSMessageContains is a predicate for ExpectMessage matching messages containing the substring.
+func WSMessageContains(substr string) func(msg []byte) bool {
+	return func(msg []byte) bool {
+		return bytes.Contains(msg, []byte(substr))
+	}
+}
+
+// WithStepTimeout limits every script step, 10 seconds by default.
+func (w *WSMock) WithStepTimeout(d time.Duration) *WSMock {
+	w.script.timeout = d
+	return w
+}
+
+// ExpectMessage waits for the next client message and fails the script if it does not match the predicate.
+func (w *WSMock) ExpectMessage(name string, pred func(msg []byte) bool) *WSMock {
+	w.script.add("expect "+name, func(ctx context.Context, c *wsConn) error {
+		select {
+		case msg, ok := <-c.incoming:
+			if !ok {
+				return fmt.Errorf("connection is closed by client")
+			}
+			if !pred(msg) {
+				return fmt.Errorf("unexpected message: %s", truncateBody(string(msg)))
+			}
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("message is not received: %w", ctx.Err())
+		}
+	})
+	return w
+}
+
+// Send sends a binary message.
+func (w *WSMock) Send(msg []byte) *WSMock {
+	w.script.add("send", func(_ context.Context, c *wsConn) error {
+		return c.write(websocket.BinaryMessage, msg)
+	})
+	return w
+}
+
+// SendText sends a text message.
+func (w *WSMock) SendText(msg string) *WSMock {
+	w.script.add("send text", func(_ context.Context, c *wsConn) error {
+		return c.write(websocket.TextMessage, []byte(msg))
+	})
+	return w
+}
+
+// SendTextAfter sends a text message after the delay.
+func (w *WSMock) SendTextAfter(d time.Duration, msg string) *WSMock {
+	w.script.add(fmt.Sprintf("send text after %s", d), func(ctx context.Context, c *wsConn) error {
+		if !sleepContext(ctx, d) {
+			return ctx.Err()
+		}
+		return c.write(websocket.TextMessage, []byte(msg))
+	})
+	return w
+}
+
+// WaitTrigger pauses the script until the test calls Trigger with the name.
+func (w *WSMock) WaitTrigger(name string) *WSMock {
+	w.script.add("wait trigger "+name, func(ctx context.Context, _ *wsConn) error {
+		return w.script.waitTrigger(ctx, name)
+	})
+	return w
+}
+
+// Close closes the connection with the close code and reason, e.g. websocket.CloseGoingAway.
+func (w *WSMock) Close(code int, reason string) *WSMock {
+	w.script.add(fmt.Sprintf("close %d", code), func(_ context.Context, c *wsConn) error {
+		return c.close(code, reason)
+	})
+	return w
+}
+
+// Trigger releases a WaitTrigger step.
+func (w *WSMock) Trigger(name string) {
+	w.script.fire(name)
+}
+
+// PushText sends a text message to the last accepted connection outside of the script.
+func (w *WSMock) PushText(msg string) error {
+	w.m.Lock()
+	c := w.conn
+	w.m.Unlock()
+	if c == nil {
+		return fmt.Errorf("no websocket connection")
+	}
+	return c.write(websocket.TextMessage, []byte(msg))
+}
+
+// Transcript returns all messages of all connections in order.
+func (w *WSMock) Transcript() []WSFrame {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return append([]WSFrame(nil), w.transcript...)
+}
+
+// Wait blocks until the script is completed by a connection and returns script errors.
+func (w *WSMock) Wait(ctx context.Context) error {
+	return w.script.wait(ctx)
+}
+
+func (w *WSMock) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	conn, err := w.upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{
+		conn:     conn,
+		mock:     w,
+		incoming: make(chan []byte, 100), //nolint:mnd // enough for scripted conversations
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.m.Lock()
+	w.conn = c
+	w.m.Unlock()
+
+	go c.readLoop()
+
+	_ = w.script.execute(r.Context(), c) //nolint:errcheck // errors are reported by Wait
+	close(c.done)
+
+	<-c.closed
+	_ = conn.Close() //nolint:errcheck
+}
+
+func (w *WSMock) record(direction string, messageType int, data []byte) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	w.transcript = append(w.transcript, WSFrame{
+		At:        time.Now(),
+		Direction: direction,
+		Type:      messageType,
+		Data:      data,
+	})
+}
+
+func (c *wsConn) readLoop() {
+	defer close(c.closed)
+	defer close(c.incoming)
+	for {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if closeErr, ok := err.(*websocket.CloseError); ok { //nolint:errorlint // ReadMessage returns unwrapped errors
+				c.mock.record(WSInbound, websocket.CloseMessage, websocket.FormatCloseMessage(closeErr.Code, closeErr.Text))
+			}
+			return
+		}
+		c.mock.record(WSInbound, messageType, data)
+		// the script reads messages in order, none is dropped while it runs
+		select {
+		case c.incoming <- data:
+		case <-c.done:
+		}
+	}
+}
+
+func (c *wsConn) write(messageType int, data []byte) error {
+	c.wm.Lock()
+	defer c.wm.Unlock()
+	if err := c.conn.WriteMessage(messageType, data); err != nil {
+		return err
+	}
+	c.mock.record(WSOutbound, messageType, data)
+	return nil
+}
+
+func (c *wsConn) close(code int, reason string) error {
+	msg := websocket.FormatCloseMessage(code, reason)
+	c.wm.Lock()
+	err := c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	c.wm.Unlock()
+	if err != nil {
+		return err
+	}
+	c.mock.record(WSOutbound, websocket.CloseMessage, msg)
+
+	select {
+	case <-c.closed:
+	case <-time.After(time.Second):
+		_ = c.conn.Close() //nolint:errcheck
+	}
+	return nil
+}